@@ -26,7 +26,15 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	r := router.Setup(cfg, db)
+	r, shutdownHardware := router.Setup(cfg, db)
+
+	// Switch the pumps off before a panic in main ends the process
+	defer func() {
+		if p := recover(); p != nil {
+			shutdownHardware()
+			panic(p)
+		}
+	}()
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -53,8 +61,11 @@ func main() {
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	// Switch every pump off and release the GPIO lines
+	shutdownHardware()
+
 	log.Println("Server exited")
 }
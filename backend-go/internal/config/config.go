@@ -14,6 +14,10 @@ type Config struct {
 	Database DatabaseConfig
 	JWT      JWTConfig
 	App      AppConfig
+	Cocktail CocktailConfig
+	GPIO     GPIOConfig
+	LoadCell LoadCellConfig
+	Pump     PumpConfig
 }
 
 type ServerConfig struct {
@@ -36,11 +40,40 @@ type JWTConfig struct {
 }
 
 type AppConfig struct {
-	Name            string
-	Version         string
-	DisableDonation bool
+	Name             string
+	Version          string
+	DisableDonation  bool
 	HideProjectLinks bool
-	DisableUpdater  bool
+	DisableUpdater   bool
+}
+
+type CocktailConfig struct {
+	QueueChangeover   time.Duration
+	ManualStepTimeout time.Duration
+}
+
+type GPIOConfig struct {
+	Driver string // gpiocdev or simulated
+	Chip   string
+}
+
+type LoadCellConfig struct {
+	PollInterval time.Duration // how often the weight is published
+	Samples      int           // readings per weight, the median is used
+	GlassWeight  int           // grams from which a glass counts as present
+	GlassMatch   int           // grams a glass may differ from its empty weight
+}
+
+// PumpConfig holds the pump watchdog limit, the H-bridge dead time and the
+// maintenance thresholds after which the tube of a pump is due for
+// replacement. Zero disables a maintenance threshold.
+type PumpConfig struct {
+	MaxRunTime        time.Duration // longest a pump may run at a time
+	DirectionDeadTime time.Duration // pause before a reversible pump changes direction
+	TubeMaxMl         int           // ml moved through a tube
+	TubeMaxRunTime    time.Duration // time a pump ran on a tube
+	TubeMaxSteps      int           // steps a stepper pump made on a tube
+	TubeMaxStarts     int           // times a pump was started on a tube
 }
 
 func Load() (*Config, error) {
@@ -64,11 +97,33 @@ func Load() (*Config, error) {
 			ExpirationTime: getEnvAsDuration("JWT_EXPIRATION", 24*time.Hour),
 		},
 		App: AppConfig{
-			Name:            getEnv("APP_NAME", "CocktailPi"),
-			Version:         getEnv("APP_VERSION", "2.0.0"),
-			DisableDonation: getEnvAsBool("APP_DISABLE_DONATION", false),
+			Name:             getEnv("APP_NAME", "CocktailPi"),
+			Version:          getEnv("APP_VERSION", "2.0.0"),
+			DisableDonation:  getEnvAsBool("APP_DISABLE_DONATION", false),
 			HideProjectLinks: getEnvAsBool("APP_HIDE_PROJECT_LINKS", false),
-			DisableUpdater:  getEnvAsBool("APP_DISABLE_UPDATER", false),
+			DisableUpdater:   getEnvAsBool("APP_DISABLE_UPDATER", false),
+		},
+		Cocktail: CocktailConfig{
+			QueueChangeover:   getEnvAsDuration("COCKTAIL_QUEUE_CHANGEOVER", 15*time.Second),
+			ManualStepTimeout: getEnvAsDuration("COCKTAIL_MANUAL_STEP_TIMEOUT", 5*time.Minute),
+		},
+		GPIO: GPIOConfig{
+			Driver: getEnv("GPIO_DRIVER", "gpiocdev"),
+			Chip:   getEnv("GPIO_CHIP", "gpiochip0"),
+		},
+		LoadCell: LoadCellConfig{
+			PollInterval: getEnvAsDuration("LOADCELL_POLL_INTERVAL", 500*time.Millisecond),
+			Samples:      getEnvAsInt("LOADCELL_SAMPLES", 5),
+			GlassWeight:  getEnvAsInt("LOADCELL_GLASS_WEIGHT", 20),
+			GlassMatch:   getEnvAsInt("LOADCELL_GLASS_MATCH", 10),
+		},
+		Pump: PumpConfig{
+			MaxRunTime:        getEnvAsDuration("PUMP_MAX_RUN_TIME", 5*time.Minute),
+			DirectionDeadTime: getEnvAsDuration("PUMP_DIRECTION_DEAD_TIME", 500*time.Millisecond),
+			TubeMaxMl:         getEnvAsInt("PUMP_TUBE_MAX_ML", 300000),
+			TubeMaxRunTime:    getEnvAsDuration("PUMP_TUBE_MAX_RUN_TIME", 0),
+			TubeMaxSteps:      getEnvAsInt("PUMP_TUBE_MAX_STEPS", 0),
+			TubeMaxStarts:     getEnvAsInt("PUMP_TUBE_MAX_STARTS", 0),
 		},
 	}
 
@@ -86,6 +141,45 @@ func (c *Config) validate() error {
 	if c.JWT.Secret == "" {
 		return fmt.Errorf("JWT secret cannot be empty")
 	}
+	if c.Cocktail.QueueChangeover < 0 {
+		return fmt.Errorf("invalid cocktail queue changeover: %s", c.Cocktail.QueueChangeover)
+	}
+	if c.Cocktail.ManualStepTimeout <= 0 {
+		return fmt.Errorf("invalid cocktail manual step timeout: %s", c.Cocktail.ManualStepTimeout)
+	}
+	if c.GPIO.Driver != "gpiocdev" && c.GPIO.Driver != "simulated" {
+		return fmt.Errorf("invalid GPIO driver: %s", c.GPIO.Driver)
+	}
+	if c.LoadCell.PollInterval <= 0 {
+		return fmt.Errorf("invalid load cell poll interval: %s", c.LoadCell.PollInterval)
+	}
+	if c.LoadCell.Samples < 1 {
+		return fmt.Errorf("invalid load cell samples: %d", c.LoadCell.Samples)
+	}
+	if c.LoadCell.GlassWeight < 1 {
+		return fmt.Errorf("invalid load cell glass weight: %d", c.LoadCell.GlassWeight)
+	}
+	if c.LoadCell.GlassMatch < 0 {
+		return fmt.Errorf("invalid load cell glass match: %d", c.LoadCell.GlassMatch)
+	}
+	if c.Pump.MaxRunTime <= 0 {
+		return fmt.Errorf("invalid pump max run time: %s", c.Pump.MaxRunTime)
+	}
+	if c.Pump.DirectionDeadTime < 0 {
+		return fmt.Errorf("invalid pump direction dead time: %s", c.Pump.DirectionDeadTime)
+	}
+	if c.Pump.TubeMaxMl < 0 {
+		return fmt.Errorf("invalid pump tube max ml: %d", c.Pump.TubeMaxMl)
+	}
+	if c.Pump.TubeMaxRunTime < 0 {
+		return fmt.Errorf("invalid pump tube max run time: %s", c.Pump.TubeMaxRunTime)
+	}
+	if c.Pump.TubeMaxSteps < 0 {
+		return fmt.Errorf("invalid pump tube max steps: %d", c.Pump.TubeMaxSteps)
+	}
+	if c.Pump.TubeMaxStarts < 0 {
+		return fmt.Errorf("invalid pump tube max starts: %d", c.Pump.TubeMaxStarts)
+	}
 	return nil
 }
 
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE cocktail_queue (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    recipe_id INTEGER NOT NULL REFERENCES recipes ON DELETE CASCADE,
+    recipe_name TEXT NOT NULL,
+    configuration TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    position INTEGER NOT NULL,
+    estimated_duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_cocktail_queue_order ON cocktail_queue(priority DESC, position ASC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_cocktail_queue_order;
+DROP TABLE IF EXISTS cocktail_queue;
+-- +goose StatementEnd
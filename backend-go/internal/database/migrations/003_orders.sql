@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE orders (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users ON DELETE SET NULL,
+    username TEXT NOT NULL,
+    recipe_id INTEGER REFERENCES recipes ON DELETE SET NULL,
+    recipe_name TEXT NOT NULL,
+    glass_id INTEGER REFERENCES glasses ON DELETE SET NULL,
+    glass_name TEXT,
+    total_amount_in_ml INTEGER NOT NULL DEFAULT 0,
+    ingredients TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error_message TEXT,
+    started_at DATETIME NOT NULL,
+    completed_at DATETIME
+);
+
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+CREATE INDEX idx_orders_started_at ON orders(started_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_orders_started_at;
+DROP INDEX IF EXISTS idx_orders_user_id;
+DROP TABLE IF EXISTS orders;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE pumps ADD COLUMN low_level_threshold_in_ml INTEGER CHECK (low_level_threshold_in_ml >= 0 OR low_level_threshold_in_ml IS NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE pumps DROP COLUMN low_level_threshold_in_ml;
+-- +goose StatementEnd
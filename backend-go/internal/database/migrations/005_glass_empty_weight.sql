@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE glasses ADD COLUMN empty_weight INTEGER CHECK (empty_weight > 0 OR empty_weight IS NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE glasses DROP COLUMN empty_weight;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE pumps ADD COLUMN last_cleaned_at DATETIME;
+ALTER TABLE pumps ADD COLUMN last_cleaned_with TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE pumps DROP COLUMN last_cleaned_with;
+ALTER TABLE pumps DROP COLUMN last_cleaned_at;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE pump_wear (
+    pump_id INTEGER NOT NULL PRIMARY KEY REFERENCES pumps ON DELETE CASCADE,
+    run_time_ms INTEGER NOT NULL DEFAULT 0,
+    steps INTEGER NOT NULL DEFAULT 0,
+    moved_ml REAL NOT NULL DEFAULT 0,
+    starts INTEGER NOT NULL DEFAULT 0,
+    tube_replaced_at DATETIME
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pump_wear;
+-- +goose StatementEnd
@@ -0,0 +1,131 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- +goose StatementBegin
+-- SQLite cannot add foreign keys to a table, so the pumps table is rebuilt.
+-- Foreign keys are off while the table is replaced, so dropping the old
+-- table keeps the wear counters. They can only be switched off outside a
+-- transaction, so the migration runs its own and checks the new table
+-- before it commits.
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+-- Pins of existing pumps were never checked, register them like saving a
+-- pump does
+INSERT OR IGNORE INTO gpio_pins (board, pin_nr)
+SELECT dc_pin_board, dc_pin_nr FROM pumps WHERE dc_pin_nr IS NOT NULL AND dc_pin_board IN (SELECT id FROM gpio_boards)
+UNION SELECT step_pin_board, step_pin_nr FROM pumps WHERE step_pin_nr IS NOT NULL AND step_pin_board IN (SELECT id FROM gpio_boards)
+UNION SELECT enable_pin_board, enable_pin_nr FROM pumps WHERE enable_pin_nr IS NOT NULL AND enable_pin_board IN (SELECT id FROM gpio_boards);
+
+
+CREATE TABLE pumps_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    dtype TEXT NOT NULL,
+    name TEXT UNIQUE,
+    completed BOOLEAN NOT NULL DEFAULT 1,
+    tube_capacity REAL,
+    current_ingredient_id INTEGER REFERENCES ingredients ON DELETE SET NULL,
+    filling_level_in_ml INTEGER NOT NULL DEFAULT 0,
+    is_pumped_up BOOLEAN NOT NULL DEFAULT 0,
+    dc_pin_board INTEGER,
+    dc_pin_nr INTEGER,
+    time_per_cl_in_ms INTEGER,
+    is_power_state_high BOOLEAN,
+    acceleration INTEGER,
+    step_pin_board INTEGER,
+    step_pin_nr INTEGER,
+    enable_pin_board INTEGER,
+    enable_pin_nr INTEGER,
+    steps_per_cl INTEGER,
+    max_steps_per_second INTEGER,
+    low_level_threshold_in_ml INTEGER CHECK (low_level_threshold_in_ml >= 0 OR low_level_threshold_in_ml IS NULL),
+    last_cleaned_at DATETIME,
+    last_cleaned_with TEXT,
+    forward_pin_board INTEGER,
+    forward_pin_nr INTEGER,
+    backward_pin_board INTEGER,
+    backward_pin_nr INTEGER,
+    CHECK (tube_capacity >= 0 OR tube_capacity IS NULL),
+    CHECK (filling_level_in_ml >= 0),
+    CHECK (time_per_cl_in_ms >= 1 OR time_per_cl_in_ms IS NULL),
+    CHECK (acceleration BETWEEN 1 AND 500000 OR acceleration IS NULL),
+    CHECK (steps_per_cl >= 1 OR steps_per_cl IS NULL),
+    CHECK (max_steps_per_second BETWEEN 1 AND 500000 OR max_steps_per_second IS NULL),
+    FOREIGN KEY (dc_pin_board, dc_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT,
+    FOREIGN KEY (step_pin_board, step_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT,
+    FOREIGN KEY (enable_pin_board, enable_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT,
+    FOREIGN KEY (forward_pin_board, forward_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT,
+    FOREIGN KEY (backward_pin_board, backward_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT
+);
+
+INSERT INTO pumps_new (id, dtype, name, completed, tube_capacity, current_ingredient_id, filling_level_in_ml, is_pumped_up, dc_pin_board, dc_pin_nr, time_per_cl_in_ms, is_power_state_high, acceleration, step_pin_board, step_pin_nr, enable_pin_board, enable_pin_nr, steps_per_cl, max_steps_per_second, low_level_threshold_in_ml, last_cleaned_at, last_cleaned_with)
+SELECT id, dtype, name, completed, tube_capacity, current_ingredient_id, filling_level_in_ml, is_pumped_up, dc_pin_board, dc_pin_nr, time_per_cl_in_ms, is_power_state_high, acceleration, step_pin_board, step_pin_nr, enable_pin_board, enable_pin_nr, steps_per_cl, max_steps_per_second, low_level_threshold_in_ml, last_cleaned_at, last_cleaned_with FROM pumps;
+
+DROP TABLE pumps;
+ALTER TABLE pumps_new RENAME TO pumps;
+CREATE INDEX idx_pumps_ingredient ON pumps(current_ingredient_id);
+
+-- Fails the migration if a pump references a missing pin or ingredient
+CREATE TEMP TABLE pumps_fk_check (violations INTEGER CHECK (violations = 0));
+INSERT INTO pumps_fk_check SELECT count(*) FROM pragma_foreign_key_check('pumps');
+DROP TABLE pumps_fk_check;
+
+COMMIT;
+PRAGMA foreign_keys = ON;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Columns in a foreign key cannot be dropped, so the table is rebuilt the
+-- same way
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE pumps_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    dtype TEXT NOT NULL,
+    name TEXT UNIQUE,
+    completed BOOLEAN NOT NULL DEFAULT 1,
+    tube_capacity REAL,
+    current_ingredient_id INTEGER REFERENCES ingredients ON DELETE SET NULL,
+    filling_level_in_ml INTEGER NOT NULL DEFAULT 0,
+    is_pumped_up BOOLEAN NOT NULL DEFAULT 0,
+    dc_pin_board INTEGER,
+    dc_pin_nr INTEGER,
+    time_per_cl_in_ms INTEGER,
+    is_power_state_high BOOLEAN,
+    acceleration INTEGER,
+    step_pin_board INTEGER,
+    step_pin_nr INTEGER,
+    enable_pin_board INTEGER,
+    enable_pin_nr INTEGER,
+    steps_per_cl INTEGER,
+    max_steps_per_second INTEGER,
+    low_level_threshold_in_ml INTEGER CHECK (low_level_threshold_in_ml >= 0 OR low_level_threshold_in_ml IS NULL),
+    last_cleaned_at DATETIME,
+    last_cleaned_with TEXT,
+    CHECK (tube_capacity >= 0 OR tube_capacity IS NULL),
+    CHECK (filling_level_in_ml >= 0),
+    CHECK (time_per_cl_in_ms >= 1 OR time_per_cl_in_ms IS NULL),
+    CHECK (acceleration BETWEEN 1 AND 500000 OR acceleration IS NULL),
+    CHECK (steps_per_cl >= 1 OR steps_per_cl IS NULL),
+    CHECK (max_steps_per_second BETWEEN 1 AND 500000 OR max_steps_per_second IS NULL),
+    FOREIGN KEY (dc_pin_board, dc_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT,
+    FOREIGN KEY (step_pin_board, step_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT,
+    FOREIGN KEY (enable_pin_board, enable_pin_nr) REFERENCES gpio_pins ON DELETE RESTRICT
+);
+
+INSERT INTO pumps_new (id, dtype, name, completed, tube_capacity, current_ingredient_id, filling_level_in_ml, is_pumped_up, dc_pin_board, dc_pin_nr, time_per_cl_in_ms, is_power_state_high, acceleration, step_pin_board, step_pin_nr, enable_pin_board, enable_pin_nr, steps_per_cl, max_steps_per_second, low_level_threshold_in_ml, last_cleaned_at, last_cleaned_with)
+SELECT id, dtype, name, completed, tube_capacity, current_ingredient_id, filling_level_in_ml, is_pumped_up, dc_pin_board, dc_pin_nr, time_per_cl_in_ms, is_power_state_high, acceleration, step_pin_board, step_pin_nr, enable_pin_board, enable_pin_nr, steps_per_cl, max_steps_per_second, low_level_threshold_in_ml, last_cleaned_at, last_cleaned_with FROM pumps;
+
+DROP TABLE pumps;
+ALTER TABLE pumps_new RENAME TO pumps;
+CREATE INDEX idx_pumps_ingredient ON pumps(current_ingredient_id);
+
+-- Fails the migration if a pump references a missing pin or ingredient
+CREATE TEMP TABLE pumps_fk_check (violations INTEGER CHECK (violations = 0));
+INSERT INTO pumps_fk_check SELECT count(*) FROM pragma_foreign_key_check('pumps');
+DROP TABLE pumps_fk_check;
+
+COMMIT;
+PRAGMA foreign_keys = ON;
+-- +goose StatementEnd
@@ -3,8 +3,11 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/middleware"
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/service"
 	"github.com/gin-gonic/gin"
 )
@@ -33,21 +36,15 @@ func (h *CocktailHandler) OrderCocktail(c *gin.Context) {
 		return
 	}
 
-	// Get user info from context (set by auth middleware)
-	userID, _ := c.Get("userID")
-	username, _ := c.Get("username")
+	claims, _ := middleware.GetClaims(c)
 
-	if err := h.service.OrderCocktail(
-		userID.(int64),
-		username.(string),
-		recipeID,
-		config,
-	); err != nil {
+	order, err := h.service.OrderCocktail(claims.UserID, claims.Username, recipeID, config)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{"message": "Cocktail order started"})
+	c.JSON(http.StatusAccepted, order)
 }
 
 // CheckFeasibility handles PUT /api/cocktail/:recipeId/feasibility
@@ -75,9 +72,9 @@ func (h *CocktailHandler) CheckFeasibility(c *gin.Context) {
 
 // CancelCocktail handles DELETE /api/cocktail
 func (h *CocktailHandler) CancelCocktail(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	claims, _ := middleware.GetClaims(c)
 
-	if err := h.service.CancelOrder(userID.(int64)); err != nil {
+	if err := h.service.CancelOrder(claims.UserID, claims.Role == models.RoleAdmin); err != nil {
 		if err.Error() == "no active order to cancel" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
@@ -109,3 +106,184 @@ func (h *CocktailHandler) GetProgress(c *gin.Context) {
 
 	c.JSON(http.StatusOK, progress)
 }
+
+// GetQueue handles GET /api/cocktail/queue
+func (h *CocktailHandler) GetQueue(c *gin.Context) {
+	queue, err := h.service.GetQueue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, queue)
+}
+
+// CancelQueuedOrder handles DELETE /api/cocktail/queue/:id
+func (h *CocktailHandler) CancelQueuedOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	claims, _ := middleware.GetClaims(c)
+
+	if err := h.service.CancelQueuedOrder(id, claims.UserID, claims.Role == models.RoleAdmin); err != nil {
+		switch err.Error() {
+		case "queued order not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "you can only cancel your own orders":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Queued order cancelled"})
+}
+
+// MoveQueuedOrder handles PUT /api/cocktail/queue/:id/position
+func (h *CocktailHandler) MoveQueuedOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req struct {
+		Position *int `json:"position" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.MoveQueuedOrder(id, *req.Position); err != nil {
+		if err.Error() == "queued order not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.GetQueue(c)
+}
+
+// SetQueuedOrderPriority handles PUT /api/cocktail/queue/:id/priority
+func (h *CocktailHandler) SetQueuedOrderPriority(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req struct {
+		Priority *int `json:"priority" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetQueuedOrderPriority(id, *req.Priority); err != nil {
+		if err.Error() == "queued order not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.GetQueue(c)
+}
+
+// GetHistory handles GET /api/cocktail/history
+func (h *CocktailHandler) GetHistory(c *gin.Context) {
+	var filter repository.OrderFilter
+	filter.Status = c.Query("status")
+
+	if userIDStr := c.Query("userId"); userIDStr != "" {
+		userID, err := strconv.ParseInt(userIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userId"})
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	if recipeIDStr := c.Query("recipeId"); recipeIDStr != "" {
+		recipeID, err := strconv.ParseInt(recipeIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipeId"})
+			return
+		}
+		filter.RecipeID = &recipeID
+	}
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected RFC 3339 time"})
+			return
+		}
+		filter.From = &from
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected RFC 3339 time"})
+			return
+		}
+		filter.To = &to
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size"})
+		return
+	}
+
+	claims, _ := middleware.GetClaims(c)
+
+	history, err := h.service.GetHistory(filter, page, size, claims.UserID, claims.Role == models.RoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetHistoryOrder handles GET /api/cocktail/history/:id
+func (h *CocktailHandler) GetHistoryOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	claims, _ := middleware.GetClaims(c)
+
+	order, err := h.service.GetHistoryOrder(id, claims.UserID, claims.Role == models.RoleAdmin)
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "you can only view your own orders":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// GpioBoardHandler handles HTTP requests for GPIO boards and their pins
+type GpioBoardHandler struct {
+	service *service.GpioBoardService
+}
+
+// NewGpioBoardHandler creates a new GPIO board handler
+func NewGpioBoardHandler(service *service.GpioBoardService) *GpioBoardHandler {
+	return &GpioBoardHandler{service: service}
+}
+
+// GetAll handles GET /api/gpio/board
+func (h *GpioBoardHandler) GetAll(c *gin.Context) {
+	boards, err := h.service.GetAllBoards()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch boards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, boards)
+}
+
+// GetByID handles GET /api/gpio/board/:id
+func (h *GpioBoardHandler) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return
+	}
+
+	board, err := h.service.GetBoard(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch board"})
+		return
+	}
+	if board == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Board not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
+
+// Create handles POST /api/gpio/board
+func (h *GpioBoardHandler) Create(c *gin.Context) {
+	var board models.GpioBoard
+	if err := c.ShouldBindJSON(&board); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.CreateBoard(&board); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, _ := h.service.GetBoard(board.ID)
+	c.JSON(http.StatusCreated, created)
+}
+
+// Update handles PUT /api/gpio/board/:id
+func (h *GpioBoardHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return
+	}
+
+	var board models.GpioBoard
+	if err := c.ShouldBindJSON(&board); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	board.ID = id
+
+	if err := h.service.UpdateBoard(&board); err != nil {
+		if err.Error() == "board not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, _ := h.service.GetBoard(id)
+	c.JSON(http.StatusOK, updated)
+}
+
+// Delete handles DELETE /api/gpio/board/:id
+func (h *GpioBoardHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return
+	}
+
+	if err := h.service.DeleteBoard(id); err != nil {
+		if err.Error() == "board not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Board deleted successfully"})
+}
+
+// GetPins handles GET /api/gpio/board/:id/pin
+func (h *GpioBoardHandler) GetPins(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return
+	}
+
+	pins, err := h.service.GetPins(id)
+	if err != nil {
+		if err.Error() == "board not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pins"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pins)
+}
+
+// CreatePin handles POST /api/gpio/board/:id/pin
+func (h *GpioBoardHandler) CreatePin(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return
+	}
+
+	var req struct {
+		PinNr *int `json:"pinNr" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pin, err := h.service.CreatePin(id, *req.PinNr)
+	if err != nil {
+		if err.Error() == "board not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, pin)
+}
+
+// DeletePin handles DELETE /api/gpio/board/:id/pin/:pinNr
+func (h *GpioBoardHandler) DeletePin(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return
+	}
+	pinNr, err := strconv.Atoi(c.Param("pinNr"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pin number"})
+		return
+	}
+
+	if err := h.service.DeletePin(id, pinNr); err != nil {
+		if err.Error() == "pin not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pin deleted successfully"})
+}
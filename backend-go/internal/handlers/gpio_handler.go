@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/service"
 	"github.com/gin-gonic/gin"
@@ -10,23 +11,68 @@ import (
 
 type GPIOHandler struct {
 	gpioService *service.GPIOService
+	pumpRuntime *service.PumpRuntime
 }
 
-func NewGPIOHandler(gpioService *service.GPIOService) *GPIOHandler {
+// NewGPIOHandler creates a new GPIO handler. gpioService may be nil when no
+// GPIO driver is available. Pins are switched on through the pump runtime,
+// so an emergency stop and the watchdog switch them off again.
+func NewGPIOHandler(gpioService *service.GPIOService, pumpRuntime *service.PumpRuntime) *GPIOHandler {
 	return &GPIOHandler{
 		gpioService: gpioService,
+		pumpRuntime: pumpRuntime,
 	}
 }
 
+// RequireGPIO aborts requests with 503 if no GPIO driver is available
+func (h *GPIOHandler) RequireGPIO(c *gin.Context) {
+	if h.gpioService == nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "GPIO service not available"})
+		return
+	}
+	c.Next()
+}
+
 // GetStatus returns the GPIO system status
 func (h *GPIOHandler) GetStatus(c *gin.Context) {
+	if h.gpioService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "unavailable",
+			"message": "GPIO service not available",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
-		"chip":    "gpiochip0",
+		"chip":    h.gpioService.DriverName(),
 		"message": "GPIO system is operational",
 	})
 }
 
+// GetTransitions returns the pin transitions recorded by the simulated driver
+func (h *GPIOHandler) GetTransitions(c *gin.Context) {
+	driver, ok := h.gpioService.Driver().(*service.SimulatedPinDriver)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GPIO driver is not simulated"})
+		return
+	}
+
+	c.JSON(http.StatusOK, driver.Transitions())
+}
+
+// ClearTransitions forgets the pin transitions recorded by the simulated driver
+func (h *GPIOHandler) ClearTransitions(c *gin.Context) {
+	driver, ok := h.gpioService.Driver().(*service.SimulatedPinDriver)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GPIO driver is not simulated"})
+		return
+	}
+
+	driver.ClearTransitions()
+	c.JSON(http.StatusOK, gin.H{"message": "Transitions cleared"})
+}
+
 // TestPin tests a GPIO pin by pulsing it
 func (h *GPIOHandler) TestPin(c *gin.Context) {
 	var req struct {
@@ -40,13 +86,12 @@ func (h *GPIOHandler) TestPin(c *gin.Context) {
 		return
 	}
 
-	// Setup and test the pin
-	if err := h.gpioService.SetupOutputPin(req.Pin); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !h.requireArmed(c) {
 		return
 	}
 
-	if err := h.gpioService.RunDCPump(req.Pin, req.DurationMs, req.ActiveHigh); err != nil {
+	duration := time.Duration(req.DurationMs) * time.Millisecond
+	if err := h.pumpRuntime.TestPin(c.Request.Context(), req.Pin, duration, req.ActiveHigh); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -76,13 +121,22 @@ func (h *GPIOHandler) RunPump(c *gin.Context) {
 		return
 	}
 
+	if req.Type != "dc" && req.Type != "stepper" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump type"})
+		return
+	}
+	if !h.requireArmed(c) {
+		return
+	}
+
 	if req.Type == "dc" {
-		if err := h.gpioService.RunDCPump(req.Pin, req.DurationMs, req.ActiveHigh); err != nil {
+		duration := time.Duration(req.DurationMs) * time.Millisecond
+		if err := h.pumpRuntime.TestPin(c.Request.Context(), req.Pin, duration, req.ActiveHigh); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "DC pump completed"})
-	} else if req.Type == "stepper" {
+	} else {
 		config := service.StepperMotorConfig{
 			StepPin:           req.StepPin,
 			EnablePin:         req.EnablePin,
@@ -90,14 +144,28 @@ func (h *GPIOHandler) RunPump(c *gin.Context) {
 			MaxStepsPerSecond: req.MaxStepsPerSecond,
 			Acceleration:      req.Acceleration,
 		}
-		if err := h.gpioService.RunStepperMotor(config); err != nil {
+		run, err := h.pumpRuntime.TestStepper(c.Request.Context(), config)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Stepper motor completed"})
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump type"})
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Stepper motor completed",
+			"steps":      run.Steps,
+			"plannedMs":  run.Planned.Milliseconds(),
+			"durationMs": run.Actual.Milliseconds(),
+		})
+	}
+}
+
+// requireArmed answers 409 and returns false while the pumps are emergency
+// stopped
+func (h *GPIOHandler) requireArmed(c *gin.Context) bool {
+	if h.pumpRuntime.IsStopped() {
+		c.JSON(http.StatusConflict, gin.H{"error": "pumps are stopped, start them again to continue"})
+		return false
 	}
+	return true
 }
 
 // GetPinValue reads the current value of a GPIO pin
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LoadCellHandler handles HTTP requests for the load cell
+type LoadCellHandler struct {
+	service        *service.LoadCellService
+	dispensingArea *service.DispensingAreaService
+}
+
+// NewLoadCellHandler creates a new load cell handler
+func NewLoadCellHandler(service *service.LoadCellService, dispensingArea *service.DispensingAreaService) *LoadCellHandler {
+	return &LoadCellHandler{service: service, dispensingArea: dispensingArea}
+}
+
+// Get handles GET /api/loadcell
+func (h *LoadCellHandler) Get(c *gin.Context) {
+	loadCell, err := h.service.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch load cell"})
+		return
+	}
+	if loadCell == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Load cell not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadCell)
+}
+
+// Save handles PUT /api/loadcell
+func (h *LoadCellHandler) Save(c *gin.Context) {
+	var loadCell models.LoadCell
+	if err := c.ShouldBindJSON(&loadCell); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Save(&loadCell); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadCell)
+}
+
+// Delete handles DELETE /api/loadcell
+func (h *LoadCellHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(); err != nil {
+		if errors.Is(err, service.ErrLoadCellNotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete load cell"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Load cell deleted successfully"})
+}
+
+// GetWeight handles GET /api/loadcell/weight
+func (h *LoadCellHandler) GetWeight(c *gin.Context) {
+	reading, err := h.service.Read(c.Request.Context())
+	if err != nil {
+		respondLoadCellError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reading)
+}
+
+// Tare handles PUT /api/loadcell/tare
+func (h *LoadCellHandler) Tare(c *gin.Context) {
+	loadCell, err := h.service.Tare(c.Request.Context())
+	if err != nil {
+		respondLoadCellError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loadCell)
+}
+
+// CalibrateZero handles PUT /api/loadcell/calibrate/zero
+func (h *LoadCellHandler) CalibrateZero(c *gin.Context) {
+	reading, err := h.service.CalibrateZero(c.Request.Context())
+	if err != nil {
+		respondLoadCellError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reading)
+}
+
+// CalibrateReference handles PUT /api/loadcell/calibrate/reference
+func (h *LoadCellHandler) CalibrateReference(c *gin.Context) {
+	var req struct {
+		WeightInGrams float64 `json:"weightInGrams" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loadCell, err := h.service.CalibrateReference(c.Request.Context(), req.WeightInGrams)
+	if err != nil {
+		respondLoadCellError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loadCell)
+}
+
+// GetDispensingArea handles GET /api/loadcell/dispensingarea
+func (h *LoadCellHandler) GetDispensingArea(c *gin.Context) {
+	state := h.dispensingArea.State()
+	if state == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Load cell not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// respondLoadCellError maps load cell errors to a response
+func respondLoadCellError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrLoadCellNotConfigured) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch err.Error() {
+	case "calibrate the empty load cell first", "reference weight must be positive":
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	}
+}
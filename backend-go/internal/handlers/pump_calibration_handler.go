@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PumpCalibrationHandler handles HTTP requests for pump calibration
+type PumpCalibrationHandler struct {
+	service *service.PumpCalibrationService
+}
+
+// NewPumpCalibrationHandler creates a new pump calibration handler
+func NewPumpCalibrationHandler(service *service.PumpCalibrationService) *PumpCalibrationHandler {
+	return &PumpCalibrationHandler{service: service}
+}
+
+// Get handles GET /api/pump/:id/calibration
+func (h *PumpCalibrationHandler) Get(c *gin.Context) {
+	pumpID, ok := parsePumpID(c)
+	if !ok {
+		return
+	}
+
+	calibration, err := h.service.Get(pumpID)
+	if err != nil {
+		respondCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, calibration)
+}
+
+// Start handles POST /api/pump/:id/calibration
+func (h *PumpCalibrationHandler) Start(c *gin.Context) {
+	pumpID, ok := parsePumpID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		IngredientID *int64 `json:"ingredientId"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	calibration, err := h.service.Start(pumpID, req.IngredientID)
+	if err != nil {
+		respondCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, calibration)
+}
+
+// Run handles POST /api/pump/:id/calibration/run
+func (h *PumpCalibrationHandler) Run(c *gin.Context) {
+	pumpID, ok := parsePumpID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DurationMs  int  `json:"durationMs"`
+		Steps       int  `json:"steps"`
+		UseLoadCell bool `json:"useLoadCell"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	calibration, err := h.service.Run(c.Request.Context(), pumpID, req.DurationMs, req.Steps, req.UseLoadCell)
+	if err != nil {
+		respondCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, calibration)
+}
+
+// Measure handles PUT /api/pump/:id/calibration/measurement
+func (h *PumpCalibrationHandler) Measure(c *gin.Context) {
+	pumpID, ok := parsePumpID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		MeasuredMl float64 `json:"measuredMl" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	calibration, err := h.service.Measure(pumpID, req.MeasuredMl)
+	if err != nil {
+		respondCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, calibration)
+}
+
+// Apply handles PUT /api/pump/:id/calibration/apply
+func (h *PumpCalibrationHandler) Apply(c *gin.Context) {
+	pumpID, ok := parsePumpID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.Apply(pumpID)
+	if err != nil {
+		respondCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Cancel handles DELETE /api/pump/:id/calibration
+func (h *PumpCalibrationHandler) Cancel(c *gin.Context) {
+	pumpID, ok := parsePumpID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Cancel(pumpID); err != nil {
+		respondCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calibration cancelled"})
+}
+
+// parsePumpID reads the pump ID path parameter. It writes a bad request
+// response and returns false if the ID is invalid.
+func parsePumpID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondCalibrationError maps calibration errors to a response
+func respondCalibrationError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "pump not found", "ingredient not found", "calibration not started":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "a calibration run is in progress":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
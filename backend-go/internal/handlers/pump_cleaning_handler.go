@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PumpCleaningHandler handles HTTP requests for cleaning the pumps
+type PumpCleaningHandler struct {
+	service *service.PumpCleaningService
+}
+
+// NewPumpCleaningHandler creates a new pump cleaning handler
+func NewPumpCleaningHandler(service *service.PumpCleaningService) *PumpCleaningHandler {
+	return &PumpCleaningHandler{service: service}
+}
+
+// Get handles GET /api/pump/clean
+func (h *PumpCleaningHandler) Get(c *gin.Context) {
+	cleaning, err := h.service.Get()
+	if err != nil {
+		respondCleaningError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cleaning)
+}
+
+// Create handles POST /api/pump/clean
+func (h *PumpCleaningHandler) Create(c *gin.Context) {
+	var req struct {
+		PumpIDs     []int64                   `json:"pumpIds" binding:"required"`
+		CleanedWith string                    `json:"cleanedWith"`
+		Steps       []models.PumpCleaningStep `json:"steps"`
+		Repeat      int                       `json:"repeat"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cleaning, err := h.service.Create(req.PumpIDs, req.CleanedWith, req.Steps, req.Repeat)
+	if err != nil {
+		respondCleaningError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, cleaning)
+}
+
+// Confirm handles PUT /api/pump/clean/confirm
+func (h *PumpCleaningHandler) Confirm(c *gin.Context) {
+	cleaning, err := h.service.Confirm()
+	if err != nil {
+		respondCleaningError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, cleaning)
+}
+
+// Cancel handles DELETE /api/pump/clean
+func (h *PumpCleaningHandler) Cancel(c *gin.Context) {
+	if err := h.service.Cancel(); err != nil {
+		respondCleaningError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cleaning cancelled"})
+}
+
+// respondCleaningError maps cleaning errors to a response
+func respondCleaningError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "pump not found", "no cleaning program", "no cleaning program to confirm", "no cleaning program to cancel":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "a cleaning program is running", "wait until the current cocktail is done":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -114,6 +114,26 @@ func (h *PumpHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Pump deleted successfully"})
 }
 
+// ResetWear handles DELETE /api/pump/:id/wear
+func (h *PumpHandler) ResetWear(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump ID"})
+		return
+	}
+
+	if err := h.service.ResetWear(id); err != nil {
+		if err.Error() == "pump not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset pump wear"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pump wear reset"})
+}
+
 // PumpUp handles PUT /api/pump/:id/pumpup
 func (h *PumpHandler) PumpUp(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -122,16 +142,27 @@ func (h *PumpHandler) PumpUp(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.SetPumpedUp(id, true); err != nil {
+	if err := h.service.PumpUp(id); err != nil {
 		if err.Error() == "pump not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Pump up initiated"})
+}
+
+// PumpUpAll handles PUT /api/pump/pumpup
+func (h *PumpHandler) PumpUpAll(c *gin.Context) {
+	pumpIDs, err := h.service.PumpUpAll()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pump up"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Pump up initiated"})
+	c.JSON(http.StatusAccepted, gin.H{"message": "Pump up initiated", "pumpIds": pumpIDs})
 }
 
 // PumpBack handles PUT /api/pump/:id/pumpback
@@ -142,62 +173,76 @@ func (h *PumpHandler) PumpBack(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.SetPumpedUp(id, false); err != nil {
+	if err := h.service.PumpBack(id); err != nil {
 		if err.Error() == "pump not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pump back"})
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Pump back initiated"})
+	c.JSON(http.StatusAccepted, gin.H{"message": "Pump back initiated"})
 }
 
 // Start handles PUT /api/pump/start
 func (h *PumpHandler) Start(c *gin.Context) {
-	idStr := c.Query("id")
-	if idStr == "" {
-		// Start all pumps
-		c.JSON(http.StatusOK, gin.H{"message": "All pumps started"})
+	pumpID, ok := parseOptionalPumpID(c)
+	if !ok {
 		return
 	}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump ID"})
+	if err := h.service.Start(pumpID); err != nil {
+		if err.Error() == "pump not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
 
-	pump, err := h.service.GetByID(id)
-	if err != nil || pump == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pump not found"})
+	if pumpID == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Pumps re-armed"})
 		return
 	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Pump started"})
 }
 
 // Stop handles PUT /api/pump/stop
 func (h *PumpHandler) Stop(c *gin.Context) {
-	idStr := c.Query("id")
-	if idStr == "" {
-		// Stop all pumps
-		c.JSON(http.StatusOK, gin.H{"message": "All pumps stopped"})
+	pumpID, ok := parseOptionalPumpID(c)
+	if !ok {
 		return
 	}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump ID"})
+	if err := h.service.Stop(pumpID); err != nil {
+		if err.Error() == "pump not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	pump, err := h.service.GetByID(id)
-	if err != nil || pump == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pump not found"})
+	if pumpID == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "All pumps stopped"})
 		return
 	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Pump stopped"})
 }
+
+// parseOptionalPumpID reads the optional id query parameter. It writes a bad
+// request response and returns false if the parameter is invalid.
+func parseOptionalPumpID(c *gin.Context) (*int64, bool) {
+	idStr := c.Query("id")
+	if idStr == "" {
+		return nil, true
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pump ID"})
+		return nil, false
+	}
+	return &id, true
+}
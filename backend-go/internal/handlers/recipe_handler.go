@@ -100,6 +100,7 @@ func (h *RecipeHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
 		return
 	}
+	recipe.OwnerID = existing.OwnerID
 
 	if err := h.service.Update(&recipe); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe"})
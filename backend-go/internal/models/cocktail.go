@@ -4,30 +4,93 @@ import "time"
 
 // CocktailOrderConfiguration represents order settings
 type CocktailOrderConfiguration struct {
-	AmountOrderedInMl int     `json:"amountOrderedInMl"`
-	BoostIngredients  []int64 `json:"boostIngredients"`
+	AmountOrderedInMl int                  `json:"amountOrderedInMl"`
+	BoostIngredients  []int64              `json:"boostIngredients"`
+	Customisations    *OrderCustomisations `json:"customisations,omitempty"`
+}
+
+// OrderCustomisations holds user adjustments to a recipe
+type OrderCustomisations struct {
+	// Boost is the strength of boostable ingredients in percent, 100 is
+	// the recipe as written
+	Boost int `json:"boost"`
 }
 
 // CocktailProgress represents the current cocktail production status
 type CocktailProgress struct {
-	RecipeID          int64     `json:"recipeId"`
-	RecipeName        string    `json:"recipeName"`
-	UserID            int64     `json:"userId"`
-	Username          string    `json:"username"`
-	CurrentStep       int       `json:"currentStep"`
-	TotalSteps        int       `json:"totalSteps"`
-	Status            string    `json:"status"` // pending, in_progress, completed, cancelled, error
-	Message           string    `json:"message"`
-	PercentComplete   int       `json:"percentComplete"`
-	StartedAt         time.Time `json:"startedAt"`
-	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+	OrderID         int64                     `json:"orderId"`
+	RecipeID        int64                     `json:"recipeId"`
+	RecipeName      string                    `json:"recipeName"`
+	UserID          int64                     `json:"userId"`
+	Username        string                    `json:"username"`
+	CurrentStep     int                       `json:"currentStep"`
+	TotalSteps      int                       `json:"totalSteps"`
+	Status          string                    `json:"status"` // idle, pending, in_progress, paused, completed, cancelled, error
+	Message         string                    `json:"message"`
+	PercentComplete int                       `json:"percentComplete"`
+	StartedAt       time.Time                 `json:"startedAt"`
+	CompletedAt     *time.Time                `json:"completedAt,omitempty"`
+	Pumps           []PumpProgress            `json:"pumps,omitempty"`
+	Queue           []QueuedOrder             `json:"queue,omitempty"`
+	ResolvedGroups  []ResolvedIngredientGroup `json:"resolvedGroups,omitempty"`
+
+	// Set while production is paused and waiting for the bartender
+	WrittenInstruction       string                  `json:"writtenInstruction,omitempty"`
+	IngredientsToAddManually []ManualIngredientToAdd `json:"ingredientsToAddManually,omitempty"`
+	PausedUntil              *time.Time              `json:"pausedUntil,omitempty"`
+}
+
+// ResolvedIngredientGroup records which ingredient was used for an ingredient group
+type ResolvedIngredientGroup struct {
+	GroupID        int64  `json:"groupId"`
+	GroupName      string `json:"groupName"`
+	IngredientID   int64  `json:"ingredientId"`
+	IngredientName string `json:"ingredientName"`
+}
+
+// ManualIngredientToAdd is an ingredient the bartender has to add by hand
+type ManualIngredientToAdd struct {
+	IngredientID   int64  `json:"ingredientId"`
+	IngredientName string `json:"ingredientName"`
+	Amount         int    `json:"amount"`
+	Unit           string `json:"unit"`
+}
+
+// PumpProgress represents the progress of a single pump within a production step
+type PumpProgress struct {
+	PumpID          int64  `json:"pumpId"`
+	PumpName        string `json:"pumpName"`
+	IngredientName  string `json:"ingredientName"`
+	AmountInMl      int    `json:"amountInMl"`
+	PercentComplete int    `json:"percentComplete"`
+	Status          string `json:"status"` // running, done, error, stopped
 }
 
 // FeasibilityReport represents whether a recipe can be made
 type FeasibilityReport struct {
-	Feasible          bool     `json:"feasible"`
-	RecipeID          int64    `json:"recipeId"`
-	MissingIngredients []string `json:"missingIngredients"`
-	InsufficientPumps  []string `json:"insufficientPumps"`
-	Message           string   `json:"message"`
+	Feasible            bool                 `json:"feasible"`
+	RecipeID            int64                `json:"recipeId"`
+	MissingIngredients  []string             `json:"missingIngredients"`
+	InsufficientPumps   []InsufficientPump   `json:"insufficientPumps"`
+	RequiredIngredients []RequiredIngredient `json:"requiredIngredients"`
+	TotalAmountInMl     int                  `json:"totalAmountInMl"`
+	EstimatedRunTimeMs  int64                `json:"estimatedRunTimeMs"`
+	Message             string               `json:"message"`
+}
+
+// RequiredIngredient is the total amount of an ingredient an order needs
+type RequiredIngredient struct {
+	Ingredient     *Ingredient `json:"ingredient"`
+	AmountRequired int         `json:"amountRequired"`
+	AmountMissing  int         `json:"amountMissing"`
+}
+
+// InsufficientPump describes a pump that does not hold enough liquid for an order
+type InsufficientPump struct {
+	PumpID            int64  `json:"pumpId"`
+	PumpName          string `json:"pumpName"`
+	IngredientName    string `json:"ingredientName"`
+	RequiredInMl      int    `json:"requiredInMl"`
+	AvailableInMl     int    `json:"availableInMl"`
+	AmountMissingInMl int    `json:"amountMissingInMl"`
 }
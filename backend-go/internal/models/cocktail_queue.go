@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// QueuedOrder is a cocktail order waiting for production
+type QueuedOrder struct {
+	ID                  int64                      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID              int64                      `gorm:"not null" json:"userId"`
+	Username            string                     `gorm:"not null" json:"username"`
+	RecipeID            int64                      `gorm:"not null" json:"recipeId"`
+	RecipeName          string                     `gorm:"not null" json:"recipeName"`
+	Configuration       CocktailOrderConfiguration `gorm:"serializer:json;not null" json:"configuration"`
+	Priority            int                        `gorm:"not null;default:0" json:"priority"`
+	Position            int                        `gorm:"not null" json:"position"`
+	EstimatedDurationMs int64                      `gorm:"not null;default:0" json:"estimatedDurationMs"`
+	CreatedAt           time.Time                  `gorm:"autoCreateTime" json:"createdAt"`
+	EstimatedStartAt    *time.Time                 `gorm:"-" json:"estimatedStartAt,omitempty"`
+	EstimatedFinishAt   *time.Time                 `gorm:"-" json:"estimatedFinishAt,omitempty"`
+}
+
+func (QueuedOrder) TableName() string {
+	return "cocktail_queue"
+}
@@ -1,9 +1,10 @@
 package models
 
 type Glass struct {
-	ID   int64  `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name string `gorm:"unique;not null" json:"name"`
-	Size int    `gorm:"not null" json:"size"`
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `gorm:"unique;not null" json:"name"`
+	Size        int    `gorm:"not null" json:"size"`
+	EmptyWeight *int   `gorm:"column:empty_weight" json:"emptyWeight"` // grams, used to recognise the glass on the load cell
 }
 
 func (Glass) TableName() string {
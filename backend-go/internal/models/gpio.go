@@ -0,0 +1,66 @@
+package models
+
+// GPIO board types
+const (
+	GpioBoardLocal = "local"
+	GpioBoardI2C   = "i2c"
+)
+
+// Supported I2C GPIO expanders
+const (
+	BoardModelMCP23017 = "MCP23017"
+	BoardModelPCF8574  = "PCF8574"
+)
+
+// GpioBoard is a source of GPIO pins, either the local GPIO chip or an
+// I2C GPIO expander
+type GpioBoard struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name       string    `gorm:"unique;not null" json:"name"`
+	DType      string    `gorm:"column:dtype;not null" json:"dtype"`
+	BoardModel *string   `json:"boardModel,omitempty"`
+	I2CAddress *int      `gorm:"column:i2c_address" json:"i2cAddress,omitempty"`
+	PinCount   int       `gorm:"-" json:"pinCount"`
+	Pins       []GpioPin `gorm:"foreignKey:Board" json:"pins,omitempty"`
+}
+
+func (GpioBoard) TableName() string {
+	return "gpio_boards"
+}
+
+// NumberOfPins returns how many pins the board provides
+func (b *GpioBoard) NumberOfPins() int {
+	if b.DType != GpioBoardI2C || b.BoardModel == nil {
+		// BCM GPIO 0-27 on the Raspberry Pi header
+		return 28
+	}
+	switch *b.BoardModel {
+	case BoardModelMCP23017:
+		return 16
+	case BoardModelPCF8574:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// GpioPin is a pin of a GPIO board that can be assigned to a function
+type GpioPin struct {
+	PinNr  int        `gorm:"primaryKey;column:pin_nr" json:"pinNr"`
+	Board  int64      `gorm:"primaryKey;column:board" json:"board"`
+	UsedBy []PinUsage `gorm:"-" json:"usedBy"`
+}
+
+func (GpioPin) TableName() string {
+	return "gpio_pins"
+}
+
+// PinUsage describes a function a pin is assigned to
+type PinUsage struct {
+	Board    int64  `json:"-"`
+	PinNr    int    `json:"-"`
+	Type     string `json:"type"` // pump, eventAction, loadCell
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Function string `json:"function"` // dcPin, stepPin, enablePin, gpioPin, dtPin, sckPin
+}
@@ -4,7 +4,7 @@ import "time"
 
 type Ingredient struct {
 	ID                 int64       `gorm:"primaryKey;autoIncrement" json:"id"`
-	DType              string      `gorm:"not null" json:"dtype"`
+	DType              string      `gorm:"column:dtype;not null" json:"dtype"`
 	Name               string      `gorm:"unique;not null" json:"name"`
 	AlcoholContent     *int        `json:"alcoholContent,omitempty"`
 	ParentGroupID      *int64      `json:"parentGroupId,omitempty"`
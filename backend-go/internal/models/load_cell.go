@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// LoadCell is an HX711 load cell under the dispensing area. The weight in
+// grams is (raw - Offset) / ReferenceUnit.
+type LoadCell struct {
+	ID            int64   `gorm:"primaryKey;autoIncrement" json:"id"`
+	DtPinBoard    int64   `gorm:"column:pin_dt_board;not null" json:"dtPinBoard"`
+	DtPinNr       int     `gorm:"column:pin_dt_nr;not null" json:"dtPinNr"`
+	SckPinBoard   int64   `gorm:"column:pin_sck_board;not null" json:"sckPinBoard"`
+	SckPinNr      int     `gorm:"column:pin_sck_nr;not null" json:"sckPinNr"`
+	ReferenceUnit float64 `gorm:"not null" json:"referenceUnit"`
+	Offset        float64 `gorm:"not null" json:"offset"`
+}
+
+func (LoadCell) TableName() string {
+	return "load_cells"
+}
+
+// Weight converts a raw HX711 reading to grams
+func (l *LoadCell) Weight(raw float64) float64 {
+	return (raw - l.Offset) / l.ReferenceUnit
+}
+
+// LoadCellReading is a weight measured by the load cell
+type LoadCellReading struct {
+	Raw           float64   `json:"raw"`
+	WeightInGrams float64   `json:"weightInGrams"`
+	At            time.Time `json:"at"`
+}
+
+// DispensingArea is the state of the area glasses are filled in, derived
+// from the load cell weight
+type DispensingArea struct {
+	Reading      *LoadCellReading `json:"reading,omitempty"`
+	GlassPresent bool             `json:"glassPresent"`
+	Glass        *Glass           `json:"glass,omitempty"` // known glass matching the empty weight
+	Filled       bool             `json:"filled"`          // an order was poured into the glass
+	Ready        bool             `json:"ready"`           // an empty glass is standing still
+	Error        string           `json:"error,omitempty"` // last failed reading, the glass state is kept
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Order is a finished cocktail order kept for the order history
+type Order struct {
+	ID              int64             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          *int64            `json:"userId"`
+	Username        string            `gorm:"not null" json:"username"`
+	RecipeID        *int64            `json:"recipeId"`
+	RecipeName      string            `gorm:"not null" json:"recipeName"`
+	GlassID         *int64            `json:"glassId"`
+	GlassName       string            `json:"glassName,omitempty"`
+	TotalAmountInMl int               `gorm:"not null;default:0" json:"totalAmountInMl"`
+	Ingredients     []OrderIngredient `gorm:"serializer:json;not null" json:"ingredients"`
+	Status          string            `gorm:"not null" json:"status"` // completed, cancelled, error
+	ErrorMessage    string            `json:"errorMessage,omitempty"`
+	StartedAt       time.Time         `gorm:"not null" json:"startedAt"`
+	CompletedAt     *time.Time        `json:"completedAt,omitempty"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+// OrderIngredient is the amount of an ingredient planned and actually
+// dispensed for an order
+type OrderIngredient struct {
+	IngredientID   int64  `json:"ingredientId"`
+	IngredientName string `json:"ingredientName"`
+	Unit           string `json:"unit"`
+	Amount         int    `json:"amount"`
+	Dispensed      int    `json:"dispensed"`
+}
+
+// OrderPage is one page of the order history
+type OrderPage struct {
+	Content       []Order `json:"content"`
+	TotalElements int64   `json:"totalElements"`
+	TotalPages    int     `json:"totalPages"`
+	Page          int     `json:"page"`
+	Size          int     `json:"size"`
+}
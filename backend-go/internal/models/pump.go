@@ -1,28 +1,104 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Pump struct {
-	ID                   int64       `gorm:"primaryKey;autoIncrement" json:"id"`
-	DType                string      `gorm:"not null" json:"dtype"`
-	Name                 *string     `gorm:"unique" json:"name,omitempty"`
-	Completed            bool        `gorm:"not null;default:true" json:"completed"`
-	TubeCapacity         *float64    `json:"tubeCapacity,omitempty"`
-	CurrentIngredientID  *int64      `json:"currentIngredientId,omitempty"`
-	CurrentIngredient    *Ingredient `gorm:"foreignKey:CurrentIngredientID" json:"currentIngredient,omitempty"`
-	FillingLevelInMl     int         `gorm:"not null;default:0" json:"fillingLevelInMl"`
-	IsPumpedUp           bool        `gorm:"not null;default:false" json:"isPumpedUp"`
-	DcPinBoard           *int64      `json:"dcPinBoard,omitempty"`
-	DcPinNr              *int        `json:"dcPinNr,omitempty"`
-	TimePerClInMs        *int        `json:"timePerClInMs,omitempty"`
-	IsPowerStateHigh     *bool       `json:"isPowerStateHigh,omitempty"`
-	Acceleration         *int        `json:"acceleration,omitempty"`
-	StepPinBoard         *int64      `json:"stepPinBoard,omitempty"`
-	StepPinNr            *int        `json:"stepPinNr,omitempty"`
-	EnablePinBoard       *int64      `json:"enablePinBoard,omitempty"`
-	EnablePinNr          *int        `json:"enablePinNr,omitempty"`
-	StepsPerCl           *int        `json:"stepsPerCl,omitempty"`
-	MaxStepsPerSecond    *int        `json:"maxStepsPerSecond,omitempty"`
+	ID                      int64       `gorm:"primaryKey;autoIncrement" json:"id"`
+	DType                   string      `gorm:"column:dtype;not null" json:"dtype"`
+	Name                    *string     `gorm:"unique" json:"name,omitempty"`
+	Completed               bool        `gorm:"not null;default:true" json:"completed"`
+	TubeCapacity            *float64    `json:"tubeCapacity,omitempty"`
+	CurrentIngredientID     *int64      `json:"currentIngredientId,omitempty"`
+	CurrentIngredient       *Ingredient `gorm:"foreignKey:CurrentIngredientID" json:"currentIngredient,omitempty"`
+	FillingLevelInMl        int         `gorm:"not null;default:0" json:"fillingLevelInMl"`
+	LowLevelThresholdInMl   *int        `json:"lowLevelThresholdInMl,omitempty"`
+	LowFillingLevel         bool        `gorm:"-" json:"lowFillingLevel"`
+	IsPumpedUp              bool        `gorm:"not null;default:false" json:"isPumpedUp"`
+	DcPinBoard              *int64      `json:"dcPinBoard,omitempty"`
+	DcPinNr                 *int        `json:"dcPinNr,omitempty"`
+	TimePerClInMs           *int        `json:"timePerClInMs,omitempty"`
+	IsPowerStateHigh        *bool       `json:"isPowerStateHigh,omitempty"`
+	ForwardPinBoard         *int64      `json:"forwardPinBoard,omitempty"`
+	ForwardPinNr            *int        `json:"forwardPinNr,omitempty"`
+	BackwardPinBoard        *int64      `json:"backwardPinBoard,omitempty"`
+	BackwardPinNr           *int        `json:"backwardPinNr,omitempty"`
+	Reversible              bool        `gorm:"-" json:"reversible"`
+	Acceleration            *int        `json:"acceleration,omitempty"`
+	StepPinBoard            *int64      `json:"stepPinBoard,omitempty"`
+	StepPinNr               *int        `json:"stepPinNr,omitempty"`
+	EnablePinBoard          *int64      `json:"enablePinBoard,omitempty"`
+	EnablePinNr             *int        `json:"enablePinNr,omitempty"`
+	StepsPerCl              *int        `json:"stepsPerCl,omitempty"`
+	MaxStepsPerSecond       *int        `json:"maxStepsPerSecond,omitempty"`
+	ValvePinBoard           *int64      `json:"valvePinBoard,omitempty"`
+	ValvePinNr              *int        `json:"valvePinNr,omitempty"`
+	ValveFlowMlPerSec       *float64    `json:"valveFlowMlPerSec,omitempty"`
+	ValveReferenceLevelInMl *int        `json:"valveReferenceLevelInMl,omitempty"`
+	ValveHeadOffsetInMl     *int        `json:"valveHeadOffsetInMl,omitempty"`
+	LastCleanedAt           *time.Time  `json:"lastCleanedAt,omitempty"`
+	LastCleanedWith         *string     `json:"lastCleanedWith,omitempty"`
+	Wear                    *PumpWear   `gorm:"-" json:"wear,omitempty"`
+	TubeReplacementDue      bool        `gorm:"-" json:"tubeReplacementDue"`
 }
 
 func (Pump) TableName() string {
 	return "pumps"
 }
+
+// AfterFind flags pumps that are empty or at or below their low level
+// threshold, and pumps that can run backwards
+func (p *Pump) AfterFind(tx *gorm.DB) error {
+	p.LowFillingLevel = p.IsLowOnLiquid()
+	p.Reversible = p.IsReversible()
+	return nil
+}
+
+// AfterSave sets the same flags on pumps that were just created or saved
+func (p *Pump) AfterSave(tx *gorm.DB) error {
+	return p.AfterFind(tx)
+}
+
+// IsReversible reports whether the pump is a DC pump with both H-bridge
+// direction pins, so it can run backwards
+func (p *Pump) IsReversible() bool {
+	return p.DType == "DcPump" &&
+		p.ForwardPinBoard != nil && p.ForwardPinNr != nil &&
+		p.BackwardPinBoard != nil && p.BackwardPinNr != nil
+}
+
+// IsLowOnLiquid reports whether the pump is empty or has reached its low
+// level threshold
+func (p *Pump) IsLowOnLiquid() bool {
+	if p.FillingLevelInMl <= 0 {
+		return true
+	}
+	return p.LowLevelThresholdInMl != nil && p.FillingLevelInMl <= *p.LowLevelThresholdInMl
+}
+
+// PumpLowLevelWarning is published on /topic/pump/lowlevel once, when an
+// order takes a pump to its low level threshold or empties it
+type PumpLowLevelWarning struct {
+	PumpID                int64  `json:"pumpId"`
+	PumpName              string `json:"pumpName"`
+	FillingLevelInMl      int    `json:"fillingLevelInMl"`
+	LowLevelThresholdInMl *int   `json:"lowLevelThresholdInMl"`
+	Empty                 bool   `json:"empty"`
+}
+
+// PumpRunningState is the state of a running pump, published on
+// /topic/pump/runningstate/{id} while the pump runs and once when it stops
+type PumpRunningState struct {
+	PumpID          int64   `json:"pumpId"`
+	Job             string  `json:"job"`       // dispense, pumpUp, pumpBack, calibration, run, continuous
+	Direction       string  `json:"direction"` // forward, backward
+	PercentDone     int     `json:"percentDone"`
+	AmountInMl      float64 `json:"amountInMl,omitempty"` // 0 for runs without a target amount
+	DispensedInMl   float64 `json:"dispensedInMl"`
+	TimeRemainingMs *int64  `json:"timeRemainingMs"` // nil for runs without an end
+	Running         bool    `json:"running"`
+	Error           string  `json:"error,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+// PumpCalibration is a calibration of a pump in progress. Without an
+// ingredient it measures the pump itself, run with water. With an ingredient
+// it measures how the ingredient flows compared to water on the calibrated
+// pump.
+type PumpCalibration struct {
+	PumpID       int64                  `json:"pumpId"`
+	IngredientID *int64                 `json:"ingredientId,omitempty"`
+	Runs         []PumpCalibrationRun   `json:"runs"`
+	Running      bool                   `json:"running"`
+	Error        string                 `json:"error,omitempty"` // why the last run failed
+	Result       *PumpCalibrationResult `json:"result,omitempty"`
+}
+
+// PumpCalibrationRun is a single run of a pump calibration
+type PumpCalibrationRun struct {
+	DurationMs int      `json:"durationMs,omitempty"` // DC and valve pumps
+	Steps      int      `json:"steps,omitempty"`      // stepper pumps
+	LevelInMl  int      `json:"levelInMl,omitempty"`  // valve pumps, filling level at the start
+	MeasuredMl *float64 `json:"measuredMl"`
+	LoadCell   bool     `json:"loadCell"` // measured by the load cell
+}
+
+// PumpCalibrationResult is the calibration averaged over all measured runs
+type PumpCalibrationResult struct {
+	TimePerClInMs           *int     `json:"timePerClInMs,omitempty"`
+	StepsPerCl              *int     `json:"stepsPerCl,omitempty"`
+	ValveFlowMlPerSec       *float64 `json:"valveFlowMlPerSec,omitempty"`
+	ValveReferenceLevelInMl *int     `json:"valveReferenceLevelInMl,omitempty"`
+	ValveHeadOffsetInMl     *int     `json:"valveHeadOffsetInMl,omitempty"` // only from runs at different levels
+	PumpTimeMultiplier      *float64 `json:"pumpTimeMultiplier,omitempty"`
+	Runs                    int      `json:"runs"`
+	SpreadPercent           float64  `json:"spreadPercent"` // difference between the slowest and fastest run
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// PumpCleaning is a cleaning program for a set of pumps. It is created
+// pending and only runs after it has been confirmed, once the pumps are
+// loaded with the cleaning liquid.
+type PumpCleaning struct {
+	PumpIDs     []int64             `json:"pumpIds"`
+	CleanedWith string              `json:"cleanedWith"`
+	Steps       []PumpCleaningStep  `json:"steps"`
+	Repeat      int                 `json:"repeat"`
+	Status      string              `json:"status"` // pending, running, done, cancelled, error
+	Message     string              `json:"message"`
+	CurrentStep int                 `json:"currentStep"` // 1 based, counting the repetitions
+	TotalSteps  int                 `json:"totalSteps"`
+	PercentDone int                 `json:"percentDone"`
+	Pumps       []PumpCleaningState `json:"pumps"`
+	Error       string              `json:"error,omitempty"`
+	StartedAt   *time.Time          `json:"startedAt,omitempty"`
+	CompletedAt *time.Time          `json:"completedAt,omitempty"`
+}
+
+// PumpCleaningStep is a step of a cleaning pattern
+type PumpCleaningStep struct {
+	Action     string `json:"action"`               // forward, soak, reverse
+	AmountInMl int    `json:"amountInMl,omitempty"` // forward and reverse, 0 is the tube capacity
+	DurationMs int    `json:"durationMs,omitempty"` // soak
+}
+
+// PumpCleaningState is the state of a single pump in a cleaning program
+type PumpCleaningState struct {
+	PumpID   int64  `json:"pumpId"`
+	PumpName string `json:"pumpName"`
+	Status   string `json:"status"` // waiting, running, done, error
+	Message  string `json:"message,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// PumpWear adds up how much a pump has run since its tube was last replaced
+type PumpWear struct {
+	PumpID         int64      `gorm:"primaryKey" json:"pumpId"`
+	RunTimeMs      int64      `gorm:"not null;default:0" json:"runTimeMs"`
+	Steps          int64      `gorm:"not null;default:0" json:"steps"`
+	MovedMl        float64    `gorm:"column:moved_ml;not null;default:0" json:"movedMl"`
+	Starts         int64      `gorm:"not null;default:0" json:"starts"`
+	TubeReplacedAt *time.Time `json:"tubeReplacedAt,omitempty"`
+}
+
+func (PumpWear) TableName() string {
+	return "pump_wear"
+}
+
+// TubeWearLimits are the maintenance thresholds after which the tube of a
+// pump is due for replacement. A zero limit is not checked.
+type TubeWearLimits struct {
+	MovedMl   float64
+	RunTimeMs int64
+	Steps     int64
+	Starts    int64
+}
+
+// TubeReplacementDue reports whether the wear has reached one of the limits
+func (w *PumpWear) TubeReplacementDue(limits TubeWearLimits) bool {
+	if w == nil {
+		return false
+	}
+	return (limits.MovedMl > 0 && w.MovedMl >= limits.MovedMl) ||
+		(limits.RunTimeMs > 0 && w.RunTimeMs >= limits.RunTimeMs) ||
+		(limits.Steps > 0 && w.Steps >= limits.Steps) ||
+		(limits.Starts > 0 && w.Starts >= limits.Starts)
+}
@@ -23,7 +23,7 @@ func (Recipe) TableName() string {
 
 type ProductionStep struct {
 	RecipeID    int64                      `gorm:"primaryKey" json:"recipeId"`
-	DType       string                     `gorm:"not null" json:"dtype"`
+	DType       string                     `gorm:"column:dtype;not null" json:"dtype"`
 	StepOrder   int                        `gorm:"primaryKey;column:step_order" json:"order"`
 	Message     string                     `json:"message,omitempty"`
 	Ingredients []ProductionStepIngredient `gorm:"foreignKey:RecipeID,StepOrder;references:RecipeID,StepOrder" json:"ingredients,omitempty"`
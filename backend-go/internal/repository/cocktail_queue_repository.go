@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// queueOrder is the order in which queued cocktails are produced
+const queueOrder = "priority DESC, position ASC, id ASC"
+
+// CocktailQueueRepository handles data access for queued cocktail orders
+type CocktailQueueRepository struct {
+	db *gorm.DB
+}
+
+// NewCocktailQueueRepository creates a new cocktail queue repository
+func NewCocktailQueueRepository(db *gorm.DB) *CocktailQueueRepository {
+	return &CocktailQueueRepository{db: db}
+}
+
+// Create appends an order to the end of the queue
+func (r *CocktailQueueRepository) Create(order *models.QueuedOrder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var maxPosition *int
+		if err := tx.Model(&models.QueuedOrder{}).Select("MAX(position)").Scan(&maxPosition).Error; err != nil {
+			return err
+		}
+		order.Position = 0
+		if maxPosition != nil {
+			order.Position = *maxPosition + 1
+		}
+		return tx.Create(order).Error
+	})
+}
+
+// FindByID returns a queued order by ID
+func (r *CocktailQueueRepository) FindByID(id int64) (*models.QueuedOrder, error) {
+	var order models.QueuedOrder
+	err := r.db.First(&order, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// FindAll returns all queued orders in production order
+func (r *CocktailQueueRepository) FindAll() ([]models.QueuedOrder, error) {
+	var orders []models.QueuedOrder
+	err := r.db.Order(queueOrder).Find(&orders).Error
+	return orders, err
+}
+
+// FindNext returns the order that is produced next
+func (r *CocktailQueueRepository) FindNext() (*models.QueuedOrder, error) {
+	var order models.QueuedOrder
+	err := r.db.Order(queueOrder).First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UpdatePriority updates the priority of a queued order
+func (r *CocktailQueueRepository) UpdatePriority(id int64, priority int) error {
+	return r.db.Model(&models.QueuedOrder{}).Where("id = ?", id).Update("priority", priority).Error
+}
+
+// SaveOrdering persists priority and position of the given orders
+func (r *CocktailQueueRepository) SaveOrdering(orders []models.QueuedOrder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, order := range orders {
+			err := tx.Model(&models.QueuedOrder{}).Where("id = ?", order.ID).
+				Updates(map[string]any{"priority": order.Priority, "position": order.Position}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes an order from the queue
+func (r *CocktailQueueRepository) Delete(id int64) error {
+	return r.db.Delete(&models.QueuedOrder{}, id).Error
+}
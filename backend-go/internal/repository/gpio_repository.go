@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GpioRepository handles data access for GPIO boards and their pins
+type GpioRepository struct {
+	db *gorm.DB
+}
+
+// NewGpioRepository creates a new GPIO repository
+func NewGpioRepository(db *gorm.DB) *GpioRepository {
+	return &GpioRepository{db: db}
+}
+
+// CreateBoard creates a new GPIO board
+func (r *GpioRepository) CreateBoard(board *models.GpioBoard) error {
+	return r.db.Omit("Pins").Create(board).Error
+}
+
+// FindBoardByID returns a GPIO board with its pins by ID
+func (r *GpioRepository) FindBoardByID(id int64) (*models.GpioBoard, error) {
+	var board models.GpioBoard
+	err := r.db.Preload("Pins", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pin_nr")
+	}).First(&board, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &board, nil
+}
+
+// FindBoardByName returns a GPIO board by name
+func (r *GpioRepository) FindBoardByName(name string) (*models.GpioBoard, error) {
+	var board models.GpioBoard
+	err := r.db.Where("name = ?", name).First(&board).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &board, nil
+}
+
+// FindAllBoards returns all GPIO boards with their pins
+func (r *GpioRepository) FindAllBoards() ([]models.GpioBoard, error) {
+	var boards []models.GpioBoard
+	err := r.db.Preload("Pins", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pin_nr")
+	}).Order("id").Find(&boards).Error
+	return boards, err
+}
+
+// UpdateBoard updates a GPIO board
+func (r *GpioRepository) UpdateBoard(board *models.GpioBoard) error {
+	return r.db.Omit("Pins").Save(board).Error
+}
+
+// DeleteBoard deletes a GPIO board together with its pins
+func (r *GpioRepository) DeleteBoard(id int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board = ?", id).Delete(&models.GpioPin{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.GpioBoard{}, id).Error
+	})
+}
+
+// FindPin returns a pin of a board
+func (r *GpioRepository) FindPin(boardID int64, pinNr int) (*models.GpioPin, error) {
+	var pin models.GpioPin
+	err := r.db.Where("board = ? AND pin_nr = ?", boardID, pinNr).First(&pin).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// CreatePin creates a new pin
+func (r *GpioRepository) CreatePin(pin *models.GpioPin) error {
+	return r.db.Create(pin).Error
+}
+
+// createMissingPins registers the pins that are not registered on their
+// boards yet, so the foreign keys of their owner hold
+func createMissingPins(tx *gorm.DB, pins []models.GpioPin) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&pins).Error
+}
+
+// DeletePin deletes a pin of a board
+func (r *GpioRepository) DeletePin(boardID int64, pinNr int) error {
+	return r.db.Where("board = ? AND pin_nr = ?", boardID, pinNr).Delete(&models.GpioPin{}).Error
+}
+
+// FindPinUsages returns every pin assignment of pumps, event actions and
+// load cells
+func (r *GpioRepository) FindPinUsages() ([]models.PinUsage, error) {
+	queries := []string{
+		`SELECT dc_pin_board AS board, dc_pin_nr AS pin_nr, 'pump' AS type, id, COALESCE(name, '#' || id) AS name, 'dcPin' AS function
+			FROM pumps WHERE dc_pin_board IS NOT NULL AND dc_pin_nr IS NOT NULL`,
+		`SELECT forward_pin_board, forward_pin_nr, 'pump', id, COALESCE(name, '#' || id), 'forwardPin'
+			FROM pumps WHERE forward_pin_board IS NOT NULL AND forward_pin_nr IS NOT NULL`,
+		`SELECT backward_pin_board, backward_pin_nr, 'pump', id, COALESCE(name, '#' || id), 'backwardPin'
+			FROM pumps WHERE backward_pin_board IS NOT NULL AND backward_pin_nr IS NOT NULL`,
+		`SELECT step_pin_board, step_pin_nr, 'pump', id, COALESCE(name, '#' || id), 'stepPin'
+			FROM pumps WHERE step_pin_board IS NOT NULL AND step_pin_nr IS NOT NULL`,
+		`SELECT enable_pin_board, enable_pin_nr, 'pump', id, COALESCE(name, '#' || id), 'enablePin'
+			FROM pumps WHERE enable_pin_board IS NOT NULL AND enable_pin_nr IS NOT NULL`,
+		`SELECT valve_pin_board, valve_pin_nr, 'pump', id, COALESCE(name, '#' || id), 'valvePin'
+			FROM pumps WHERE valve_pin_board IS NOT NULL AND valve_pin_nr IS NOT NULL`,
+		`SELECT gpio_board, gpio_pin, 'eventAction', id, name, 'gpioPin'
+			FROM event_actions WHERE gpio_board IS NOT NULL AND gpio_pin IS NOT NULL`,
+		`SELECT pin_dt_board, pin_dt_nr, 'loadCell', id, 'Load cell #' || id, 'dtPin' FROM load_cells`,
+		`SELECT pin_sck_board, pin_sck_nr, 'loadCell', id, 'Load cell #' || id, 'sckPin' FROM load_cells`,
+	}
+
+	var usages []models.PinUsage
+	err := r.db.Raw(strings.Join(queries, " UNION ALL ")).Scan(&usages).Error
+	return usages, err
+}
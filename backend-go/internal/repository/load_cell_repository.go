@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// LoadCellRepository handles data access for load cells
+type LoadCellRepository struct {
+	db *gorm.DB
+}
+
+// NewLoadCellRepository creates a new load cell repository
+func NewLoadCellRepository(db *gorm.DB) *LoadCellRepository {
+	return &LoadCellRepository{db: db}
+}
+
+// Find returns the load cell of the dispensing area
+func (r *LoadCellRepository) Find() (*models.LoadCell, error) {
+	var loadCell models.LoadCell
+	err := r.db.Order("id").First(&loadCell).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &loadCell, nil
+}
+
+// Save creates or updates a load cell
+func (r *LoadCellRepository) Save(loadCell *models.LoadCell) error {
+	return r.db.Save(loadCell).Error
+}
+
+// SaveWithPins creates or updates a load cell and registers the pins it uses
+func (r *LoadCellRepository) SaveWithPins(loadCell *models.LoadCell, pins []models.GpioPin) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := createMissingPins(tx, pins); err != nil {
+			return err
+		}
+		return tx.Save(loadCell).Error
+	})
+}
+
+// Delete deletes a load cell by ID
+func (r *LoadCellRepository) Delete(id int64) error {
+	return r.db.Delete(&models.LoadCell{}, id).Error
+}
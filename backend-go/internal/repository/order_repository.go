@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// OrderFilter narrows down the order history. Nil fields are ignored.
+type OrderFilter struct {
+	UserID   *int64
+	RecipeID *int64
+	Status   string
+	From     *time.Time
+	To       *time.Time
+}
+
+// OrderRepository handles data access for the order history
+type OrderRepository struct {
+	db *gorm.DB
+}
+
+// NewOrderRepository creates a new order repository
+func NewOrderRepository(db *gorm.DB) *OrderRepository {
+	return &OrderRepository{db: db}
+}
+
+// Create stores a finished order, removes it from the cocktail queue and
+// subtracts the liquid dispensed by each pump from its filling level in the
+// same transaction. pumpUsage maps pump IDs to the dispensed amount in ml.
+func (r *OrderRepository) Create(order *models.Order, pumpUsage map[int64]int, queuedOrderID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.QueuedOrder{}, queuedOrderID).Error; err != nil {
+			return err
+		}
+		for pumpID, amount := range pumpUsage {
+			if amount <= 0 {
+				continue
+			}
+			err := tx.Model(&models.Pump{}).Where("id = ?", pumpID).
+				Update("filling_level_in_ml", gorm.Expr("MAX(filling_level_in_ml - ?, 0)", amount)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindByID returns an order by ID
+func (r *OrderRepository) FindByID(id int64) (*models.Order, error) {
+	var order models.Order
+	err := r.db.First(&order, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// FindByFilter returns one page of orders matching the filter, newest first,
+// together with the total number of matching orders
+func (r *OrderRepository) FindByFilter(filter OrderFilter, page int, size int) ([]models.Order, int64, error) {
+	query := r.db.Model(&models.Order{})
+
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.RecipeID != nil {
+		query = query.Where("recipe_id = ?", *filter.RecipeID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("started_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("started_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	err := query.Order("started_at DESC, id DESC").
+		Offset(page * size).Limit(size).
+		Find(&orders).Error
+	return orders, total, err
+}
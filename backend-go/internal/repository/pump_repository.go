@@ -1,10 +1,17 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
 
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // PumpRepository handles data access for pumps
@@ -17,9 +24,14 @@ func NewPumpRepository(db *gorm.DB) *PumpRepository {
 	return &PumpRepository{db: db}
 }
 
-// Create creates a new pump
-func (r *PumpRepository) Create(pump *models.Pump) error {
-	return r.db.Create(pump).Error
+// Create creates a new pump and registers the pins it uses
+func (r *PumpRepository) Create(pump *models.Pump, pins []models.GpioPin) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := createMissingPins(tx, pins); err != nil {
+			return err
+		}
+		return tx.Create(pump).Error
+	})
 }
 
 // FindByID returns a pump by ID
@@ -32,19 +44,34 @@ func (r *PumpRepository) FindByID(id int64) (*models.Pump, error) {
 		}
 		return nil, err
 	}
-	return &pump, nil
+
+	pumps := []models.Pump{pump}
+	if err := r.attachWear(pumps); err != nil {
+		return nil, err
+	}
+	return &pumps[0], nil
 }
 
 // FindAll returns all pumps
 func (r *PumpRepository) FindAll() ([]models.Pump, error) {
 	var pumps []models.Pump
-	err := r.db.Preload("CurrentIngredient").Order("id").Find(&pumps).Error
-	return pumps, err
+	if err := r.db.Preload("CurrentIngredient").Order("id").Find(&pumps).Error; err != nil {
+		return nil, err
+	}
+	if err := r.attachWear(pumps); err != nil {
+		return nil, err
+	}
+	return pumps, nil
 }
 
-// Update updates a pump
-func (r *PumpRepository) Update(pump *models.Pump) error {
-	return r.db.Save(pump).Error
+// Update updates a pump and registers the pins it uses
+func (r *PumpRepository) Update(pump *models.Pump, pins []models.GpioPin) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := createMissingPins(tx, pins); err != nil {
+			return err
+		}
+		return tx.Save(pump).Error
+	})
 }
 
 // UpdateFields updates specific fields of a pump
@@ -52,9 +79,120 @@ func (r *PumpRepository) UpdateFields(id int64, fields map[string]any) error {
 	return r.db.Model(&models.Pump{}).Where("id = ?", id).Updates(fields).Error
 }
 
-// Delete deletes a pump by ID
+// UpdateFieldsWithPins updates specific fields of a pump and registers the
+// pins it uses
+func (r *PumpRepository) UpdateFieldsWithPins(id int64, fields map[string]any, pins []models.GpioPin) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := createMissingPins(tx, pins); err != nil {
+			return err
+		}
+		return tx.Model(&models.Pump{}).Where("id = ?", id).Updates(fields).Error
+	})
+}
+
+// ApplyFields sets the columns of a partial update on a pump in memory, so
+// the merged pump can be validated before the update is saved
+func (r *PumpRepository) ApplyFields(pump *models.Pump, fields map[string]any) error {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(pump); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	value := reflect.ValueOf(pump).Elem()
+	for column, v := range fields {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return fmt.Errorf("unknown pump field: %s", column)
+		}
+		if number, ok := v.(float64); ok && field.DataType == schema.Int && number != math.Trunc(number) {
+			return fmt.Errorf("invalid %s: %v is not a whole number", column, v)
+		}
+		if err := field.Set(ctx, value, v); err != nil {
+			return fmt.Errorf("invalid %s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// ReduceFillingLevel takes amountMl from the filling level of a pump,
+// stopping at zero
+func (r *PumpRepository) ReduceFillingLevel(id int64, amountMl int) error {
+	return r.db.Model(&models.Pump{}).Where("id = ?", id).
+		Update("filling_level_in_ml", gorm.Expr("MAX(filling_level_in_ml - ?, 0)", amountMl)).Error
+}
+
+// AddFillingLevel adds amountMl to the filling level of a pump, for liquid
+// that was pumped back into the bottle
+func (r *PumpRepository) AddFillingLevel(id int64, amountMl int) error {
+	return r.db.Model(&models.Pump{}).Where("id = ?", id).
+		Update("filling_level_in_ml", gorm.Expr("filling_level_in_ml + ?", amountMl)).Error
+}
+
+// Delete deletes a pump by ID together with its wear counters
 func (r *PumpRepository) Delete(id int64) error {
-	return r.db.Delete(&models.Pump{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.PumpWear{}, id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Pump{}, id).Error
+	})
+}
+
+// AddWear adds the usage of a pump run to the wear counters of a pump and
+// returns the new totals
+func (r *PumpRepository) AddWear(usage models.PumpWear) (*models.PumpWear, error) {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "pump_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"run_time_ms": gorm.Expr("run_time_ms + ?", usage.RunTimeMs),
+			"steps":       gorm.Expr("steps + ?", usage.Steps),
+			"moved_ml":    gorm.Expr("moved_ml + ?", usage.MovedMl),
+			"starts":      gorm.Expr("starts + ?", usage.Starts),
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var wear models.PumpWear
+	if err := r.db.First(&wear, usage.PumpID).Error; err != nil {
+		return nil, err
+	}
+	return &wear, nil
+}
+
+// ResetWear clears the wear counters of a pump after its tube was replaced
+func (r *PumpRepository) ResetWear(id int64, replacedAt time.Time) error {
+	return r.db.Save(&models.PumpWear{PumpID: id, TubeReplacedAt: &replacedAt}).Error
+}
+
+// attachWear loads the wear counters of the pumps. Pumps that never ran
+// get empty counters.
+func (r *PumpRepository) attachWear(pumps []models.Pump) error {
+	if len(pumps) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(pumps))
+	for i := range pumps {
+		ids[i] = pumps[i].ID
+	}
+	var wear []models.PumpWear
+	if err := r.db.Where("pump_id IN ?", ids).Find(&wear).Error; err != nil {
+		return err
+	}
+
+	byPump := make(map[int64]models.PumpWear, len(wear))
+	for _, w := range wear {
+		byPump[w.PumpID] = w
+	}
+	for i := range pumps {
+		w := byPump[pumps[i].ID]
+		w.PumpID = pumps[i].ID
+		pumps[i].Wear = &w
+	}
+	return nil
 }
 
 // FindByIngredientID returns pumps containing a specific ingredient
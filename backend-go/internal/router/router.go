@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"io/fs"
 	"log"
 	"net/http"
@@ -20,13 +21,15 @@ import (
 	"gorm.io/gorm"
 )
 
-func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
+// Setup creates the router with all services. The returned function
+// switches every pump off and closes the GPIO boards; call it on shutdown.
+func Setup(cfg *config.Config, db *gorm.DB) (*gin.Engine, func()) {
 	if cfg.Server.Port != 8080 {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
-	r.Use(middleware.CORS())
+	r := gin.New()
+	r.Use(gin.Logger())
 
 	userRepo := repository.NewUserRepository(db)
 	recipeRepo := repository.NewRecipeRepository(db)
@@ -34,26 +37,72 @@ func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
 	glassRepo := repository.NewGlassRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
 	pumpRepo := repository.NewPumpRepository(db)
+	cocktailQueueRepo := repository.NewCocktailQueueRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	gpioRepo := repository.NewGpioRepository(db)
+	loadCellRepo := repository.NewLoadCellRepository(db)
+
+	wsHub := websocket.NewHub()
+	go wsHub.Run()
+
+	// STOMP server for SockJS compatibility
+	stompServer := websocket.NewStompServer(wsHub)
+	wsService := websocket.NewService(stompServer)
 
 	userService := service.NewUserService(userRepo)
 	recipeService := service.NewRecipeService(recipeRepo)
-	ingredientService := service.NewIngredientService(ingredientRepo)
+	ingredientService := service.NewIngredientService(ingredientRepo, wsService)
 	glassService := service.NewGlassService(glassRepo)
 	categoryService := service.NewCategoryService(categoryRepo)
-	pumpService := service.NewPumpService(pumpRepo, ingredientRepo)
 	systemService := service.NewSystemService(cfg)
-	cocktailService := service.NewCocktailService(recipeRepo, ingredientRepo, pumpRepo)
-	imageService := service.NewImageService("./images")
 
-	if err := userService.EnsureDefaultAdmin(); err != nil {
-		panic(err)
-	}
-
-	// Initialize GPIO service (may fail on non-Raspberry Pi systems)
-	gpioService, err := service.NewGPIOService()
+	// Initialize GPIO service (the gpiocdev driver fails on non-Raspberry Pi
+	// systems, use GPIO_DRIVER=simulated there)
+	var gpioService *service.GPIOService
+	pinDriver, err := service.NewPinDriver(cfg.GPIO)
 	if err != nil {
 		// Log warning but don't panic - GPIO may not be available
 		println("Warning: GPIO service not available:", err.Error())
+	} else {
+		gpioService = service.NewGPIOService(pinDriver)
+	}
+
+	// Pumps on I2C expander boards use the I2C bus from the I2C settings,
+	// the simulated driver uses a fake bus instead
+	openI2CBus := service.OpenI2CBus
+	if cfg.GPIO.Driver == "simulated" {
+		openI2CBus = func(busID int) (service.I2CBus, error) {
+			return service.NewSimulatedI2CBus(gpioRepo), nil
+		}
+	}
+	gpioBoards := service.NewGPIOBoards(gpioService, gpioRepo, systemService.GetI2CSettings, openI2CBus)
+	gpioBoardService := service.NewGpioBoardService(gpioRepo, gpioBoards)
+
+	pumpRuntime := service.NewPumpRuntime(cfg, gpioBoards, wsService)
+
+	// A handler that panics may have left a pin active, so a panic stops
+	// all pumps like an emergency stop
+	r.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		pumpRuntime.EmergencyStop()
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}))
+	r.Use(middleware.CORS())
+
+	loadCellService := service.NewLoadCellService(cfg, loadCellRepo, gpioRepo, gpioBoards)
+	dispensingAreaService := service.NewDispensingAreaService(cfg, loadCellService, glassRepo, wsService)
+	go dispensingAreaService.Run(context.Background())
+	pumpService := service.NewPumpService(cfg, pumpRepo, ingredientRepo, gpioRepo, pumpRuntime, wsService)
+	// Switch off any pump left running by a crashed or killed process
+	pumpService.ResetOutputs()
+	pumpCalibrationService := service.NewPumpCalibrationService(pumpService, ingredientService, pumpRuntime, loadCellService)
+
+	pumpCleaningService := service.NewPumpCleaningService(pumpService, pumpRuntime, wsService)
+
+	cocktailService := service.NewCocktailService(cfg, recipeRepo, ingredientRepo, pumpRepo, cocktailQueueRepo, orderRepo, pumpRuntime, dispensingAreaService, pumpCleaningService, wsService)
+	imageService := service.NewImageService("./images")
+
+	if err := userService.EnsureDefaultAdmin(); err != nil {
+		panic(err)
 	}
 
 	jwtService := auth.NewJWTService(cfg)
@@ -65,19 +114,14 @@ func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
 	glassHandler := handlers.NewGlassHandler(glassService)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 	pumpHandler := handlers.NewPumpHandler(pumpService)
+	pumpCalibrationHandler := handlers.NewPumpCalibrationHandler(pumpCalibrationService)
+	pumpCleaningHandler := handlers.NewPumpCleaningHandler(pumpCleaningService)
 	systemHandler := handlers.NewSystemHandler(systemService)
 	cocktailHandler := handlers.NewCocktailHandler(cocktailService)
 
-	var gpioHandler *handlers.GPIOHandler
-	if gpioService != nil {
-		gpioHandler = handlers.NewGPIOHandler(gpioService)
-	}
-
-	wsHub := websocket.NewHub()
-	go wsHub.Run()
-
-	// STOMP server for SockJS compatibility
-	stompServer := websocket.NewStompServer(wsHub)
+	gpioHandler := handlers.NewGPIOHandler(gpioService, pumpRuntime)
+	gpioBoardHandler := handlers.NewGpioBoardHandler(gpioBoardService)
+	loadCellHandler := handlers.NewLoadCellHandler(loadCellService, dispensingAreaService)
 
 	// WebSocket endpoints - support both /websocket (SockJS pattern) and /api/ws
 	r.GET("/websocket", func(c *gin.Context) {
@@ -157,9 +201,21 @@ func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
 			pumpGroup.POST("", middleware.RequireRole(models.RoleAdmin), pumpHandler.Create)
 			pumpGroup.PATCH("/:id", pumpHandler.Update)
 			pumpGroup.DELETE("/:id", middleware.RequireRole(models.RoleAdmin), pumpHandler.Delete)
-			pumpGroup.PUT("/:id/pumpup", pumpHandler.PumpUp)
-			pumpGroup.PUT("/:id/pumpback", pumpHandler.PumpBack)
-			pumpGroup.PUT("/start", pumpHandler.Start)
+			pumpGroup.PUT("/pumpup", middleware.RequireRole(models.RoleAdmin), pumpHandler.PumpUpAll)
+			pumpGroup.GET("/clean", middleware.RequireRole(models.RoleAdmin), pumpCleaningHandler.Get)
+			pumpGroup.POST("/clean", middleware.RequireRole(models.RoleAdmin), pumpCleaningHandler.Create)
+			pumpGroup.PUT("/clean/confirm", middleware.RequireRole(models.RoleAdmin), pumpCleaningHandler.Confirm)
+			pumpGroup.DELETE("/clean", middleware.RequireRole(models.RoleAdmin), pumpCleaningHandler.Cancel)
+			pumpGroup.PUT("/:id/pumpup", middleware.RequireRole(models.RoleAdmin), pumpHandler.PumpUp)
+			pumpGroup.PUT("/:id/pumpback", middleware.RequireRole(models.RoleAdmin), pumpHandler.PumpBack)
+			pumpGroup.DELETE("/:id/wear", middleware.RequireRole(models.RoleAdmin), pumpHandler.ResetWear)
+			pumpGroup.GET("/:id/calibration", middleware.RequireRole(models.RoleAdmin), pumpCalibrationHandler.Get)
+			pumpGroup.POST("/:id/calibration", middleware.RequireRole(models.RoleAdmin), pumpCalibrationHandler.Start)
+			pumpGroup.DELETE("/:id/calibration", middleware.RequireRole(models.RoleAdmin), pumpCalibrationHandler.Cancel)
+			pumpGroup.POST("/:id/calibration/run", middleware.RequireRole(models.RoleAdmin), pumpCalibrationHandler.Run)
+			pumpGroup.PUT("/:id/calibration/measurement", middleware.RequireRole(models.RoleAdmin), pumpCalibrationHandler.Measure)
+			pumpGroup.PUT("/:id/calibration/apply", middleware.RequireRole(models.RoleAdmin), pumpCalibrationHandler.Apply)
+			pumpGroup.PUT("/start", middleware.RequireRole(models.RoleAdmin), pumpHandler.Start)
 			pumpGroup.PUT("/stop", pumpHandler.Stop)
 		}
 
@@ -171,6 +227,12 @@ func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
 			cocktailGroup.DELETE("", cocktailHandler.CancelCocktail)
 			cocktailGroup.POST("/continueproduction", cocktailHandler.ContinueProduction)
 			cocktailGroup.GET("/progress", cocktailHandler.GetProgress)
+			cocktailGroup.GET("/queue", cocktailHandler.GetQueue)
+			cocktailGroup.GET("/history", cocktailHandler.GetHistory)
+			cocktailGroup.GET("/history/:id", cocktailHandler.GetHistoryOrder)
+			cocktailGroup.DELETE("/queue/:id", cocktailHandler.CancelQueuedOrder)
+			cocktailGroup.PUT("/queue/:id/position", middleware.RequireRole(models.RoleAdmin), cocktailHandler.MoveQueuedOrder)
+			cocktailGroup.PUT("/queue/:id/priority", middleware.RequireRole(models.RoleAdmin), cocktailHandler.SetQueuedOrderPriority)
 		}
 
 		systemGroup := api.Group("/system")
@@ -189,17 +251,40 @@ func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
 			systemGroup.PUT("/shutdown", middleware.RequireRole(models.RoleAdmin), systemHandler.Shutdown)
 		}
 
-		// GPIO routes (only if GPIO service is available)
-		if gpioHandler != nil {
-			gpioGroup := api.Group("/gpio")
-			gpioGroup.Use(middleware.AuthMiddleware(jwtService))
-			{
-				gpioGroup.GET("/status", gpioHandler.GetStatus)
-				gpioGroup.POST("/test", gpioHandler.TestPin)
-				gpioGroup.POST("/pump", gpioHandler.RunPump)
-				gpioGroup.GET("/pin/:pin", gpioHandler.GetPinValue)
-				gpioGroup.DELETE("/pin/:pin", gpioHandler.ReleasePin)
-			}
+		// GPIO routes answer 503 if no GPIO driver is available
+		gpioGroup := api.Group("/gpio")
+		gpioGroup.Use(middleware.AuthMiddleware(jwtService))
+		{
+			gpioGroup.GET("/status", gpioHandler.GetStatus)
+			gpioGroup.POST("/test", middleware.RequireRole(models.RoleAdmin), gpioHandler.RequireGPIO, gpioHandler.TestPin)
+			gpioGroup.POST("/pump", middleware.RequireRole(models.RoleAdmin), gpioHandler.RequireGPIO, gpioHandler.RunPump)
+			gpioGroup.GET("/pin/:pin", gpioHandler.RequireGPIO, gpioHandler.GetPinValue)
+			gpioGroup.DELETE("/pin/:pin", middleware.RequireRole(models.RoleAdmin), gpioHandler.RequireGPIO, gpioHandler.ReleasePin)
+			gpioGroup.GET("/simulation/transitions", gpioHandler.RequireGPIO, gpioHandler.GetTransitions)
+			gpioGroup.DELETE("/simulation/transitions", gpioHandler.RequireGPIO, gpioHandler.ClearTransitions)
+
+			// Board and pin configuration works without GPIO hardware
+			gpioGroup.GET("/board", gpioBoardHandler.GetAll)
+			gpioGroup.GET("/board/:id", gpioBoardHandler.GetByID)
+			gpioGroup.POST("/board", middleware.RequireRole(models.RoleAdmin), gpioBoardHandler.Create)
+			gpioGroup.PUT("/board/:id", middleware.RequireRole(models.RoleAdmin), gpioBoardHandler.Update)
+			gpioGroup.DELETE("/board/:id", middleware.RequireRole(models.RoleAdmin), gpioBoardHandler.Delete)
+			gpioGroup.GET("/board/:id/pin", gpioBoardHandler.GetPins)
+			gpioGroup.POST("/board/:id/pin", middleware.RequireRole(models.RoleAdmin), gpioBoardHandler.CreatePin)
+			gpioGroup.DELETE("/board/:id/pin/:pinNr", middleware.RequireRole(models.RoleAdmin), gpioBoardHandler.DeletePin)
+		}
+
+		loadCellGroup := api.Group("/loadcell")
+		loadCellGroup.Use(middleware.AuthMiddleware(jwtService))
+		{
+			loadCellGroup.GET("", loadCellHandler.Get)
+			loadCellGroup.PUT("", middleware.RequireRole(models.RoleAdmin), loadCellHandler.Save)
+			loadCellGroup.DELETE("", middleware.RequireRole(models.RoleAdmin), loadCellHandler.Delete)
+			loadCellGroup.GET("/weight", loadCellHandler.GetWeight)
+			loadCellGroup.PUT("/tare", middleware.RequireRole(models.RoleAdmin), loadCellHandler.Tare)
+			loadCellGroup.PUT("/calibrate/zero", middleware.RequireRole(models.RoleAdmin), loadCellHandler.CalibrateZero)
+			loadCellGroup.PUT("/calibrate/reference", middleware.RequireRole(models.RoleAdmin), loadCellHandler.CalibrateReference)
+			loadCellGroup.GET("/dispensingarea", loadCellHandler.GetDispensingArea)
 		}
 
 		api.GET("/ws", func(c *gin.Context) {
@@ -222,7 +307,15 @@ func Setup(cfg *config.Config, db *gorm.DB) *gin.Engine {
 		log.Println("Running in standalone mode - API-only, no frontend serving")
 	}
 
-	return r
+	shutdown := func() {
+		pumpRuntime.EmergencyStop()
+		pumpService.ResetOutputs()
+		if err := gpioBoards.Close(); err != nil {
+			log.Printf("Failed to close GPIO: %v", err)
+		}
+	}
+
+	return r, shutdown
 }
 
 // setupStaticFileServer configures serving of static frontend files
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
+)
+
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+)
+
+// GetHistory returns one page of the order history. Users only see their
+// own orders, admins see all orders and may filter by user.
+func (s *CocktailService) GetHistory(filter repository.OrderFilter, page int, size int, userID int64, isAdmin bool) (*models.OrderPage, error) {
+	if !isAdmin {
+		filter.UserID = &userID
+	}
+	if page < 0 {
+		page = 0
+	}
+	if size <= 0 {
+		size = defaultHistoryPageSize
+	}
+	if size > maxHistoryPageSize {
+		size = maxHistoryPageSize
+	}
+
+	orders, total, err := s.orderRepo.FindByFilter(filter, page, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+	if orders == nil {
+		orders = []models.Order{}
+	}
+
+	return &models.OrderPage{
+		Content:       orders,
+		TotalElements: total,
+		TotalPages:    int((total + int64(size) - 1) / int64(size)),
+		Page:          page,
+		Size:          size,
+	}, nil
+}
+
+// GetHistoryOrder returns a single order of the order history
+func (s *CocktailService) GetHistoryOrder(id int64, userID int64, isAdmin bool) (*models.Order, error) {
+	order, err := s.orderRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find order: %w", err)
+	}
+	if order == nil {
+		return nil, errors.New("order not found")
+	}
+
+	if !isAdmin && (order.UserID == nil || *order.UserID != userID) {
+		return nil, errors.New("you can only view your own orders")
+	}
+
+	return order, nil
+}
+
+// recordOrder stores a finished order in the order history and removes it
+// from the queue. plan is nil for orders that failed before production
+// started.
+func (s *CocktailService) recordOrder(progress models.CocktailProgress, plan *productionPlan) {
+	userID := progress.UserID
+	recipeID := progress.RecipeID
+	order := &models.Order{
+		UserID:      &userID,
+		Username:    progress.Username,
+		RecipeID:    &recipeID,
+		RecipeName:  progress.RecipeName,
+		Ingredients: []models.OrderIngredient{},
+		Status:      progress.Status,
+		StartedAt:   progress.StartedAt,
+		CompletedAt: progress.CompletedAt,
+	}
+	if progress.Status != "completed" {
+		order.ErrorMessage = progress.Message
+	}
+	var pumpUsage map[int64]int
+	var wasLow map[int64]bool
+	if plan != nil {
+		pumpUsage = plan.pumpUsage()
+		wasLow = s.lowPumps()
+		order.TotalAmountInMl = plan.TotalAmountInMl
+		order.Ingredients = plan.orderIngredients()
+		if plan.Glass != nil {
+			glassID := plan.Glass.ID
+			order.GlassID = &glassID
+			order.GlassName = plan.Glass.Name
+		}
+	}
+
+	if err := s.orderRepo.Create(order, pumpUsage, progress.OrderID); err != nil {
+		log.Printf("Failed to record order %d in history: %v", progress.OrderID, err)
+		// The order is done either way and must not be produced again
+		if err := s.queueRepo.Delete(progress.OrderID); err != nil {
+			log.Printf("Failed to dequeue order %d: %v", progress.OrderID, err)
+		}
+		return
+	}
+
+	if len(pumpUsage) > 0 {
+		s.publishPumpLevels(pumpUsage, wasLow)
+	}
+}
+
+// lowPumps returns the pumps that are already low on liquid
+func (s *CocktailService) lowPumps() map[int64]bool {
+	pumps, err := s.pumpRepo.FindAll()
+	if err != nil {
+		log.Printf("Failed to get pumps: %v", err)
+		return nil
+	}
+
+	low := make(map[int64]bool)
+	for _, pump := range pumps {
+		if pump.LowFillingLevel {
+			low[pump.ID] = true
+		}
+	}
+	return low
+}
+
+// publishPumpLevels pushes the pump layout with updated filling levels and
+// warns about every used pump that ran low with this order. Pumps in wasLow
+// were low before and are not warned about again.
+func (s *CocktailService) publishPumpLevels(pumpUsage map[int64]int, wasLow map[int64]bool) {
+	pumps, err := s.pumpRepo.FindAll()
+	if err != nil {
+		log.Printf("Failed to get pumps: %v", err)
+		return
+	}
+
+	for i := range pumps {
+		pump := &pumps[i]
+		if _, used := pumpUsage[pump.ID]; !used || !pump.LowFillingLevel || wasLow[pump.ID] {
+			continue
+		}
+		log.Printf("Warning: pump %s is low on liquid (%d ml left)", pumpDisplayName(pump), pump.FillingLevelInMl)
+		if s.publisher != nil {
+			s.publisher.BroadcastPumpLowLevel(models.PumpLowLevelWarning{
+				PumpID:                pump.ID,
+				PumpName:              pumpDisplayName(pump),
+				FillingLevelInMl:      pump.FillingLevelInMl,
+				LowLevelThresholdInMl: pump.LowLevelThresholdInMl,
+				Empty:                 pump.FillingLevelInMl <= 0,
+			})
+		}
+	}
+
+	if s.publisher != nil {
+		flagTubeWear(pumps, s.wearLimits)
+		s.publisher.BroadcastPumpLayout(pumps)
+	}
+}
@@ -0,0 +1,638 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// defaultBoostPercent is the extra amount applied to boosted ingredients
+const defaultBoostPercent = 25
+
+// productionPlan is a recipe scaled to an order and matched against the pumps
+type productionPlan struct {
+	Steps           []plannedStep
+	TotalAmountInMl int
+	Glass           *models.Glass
+}
+
+// plannedStep is a production step with its ingredients scaled for the order
+type plannedStep struct {
+	Order       int
+	DType       string
+	Message     string
+	Ingredients []plannedIngredient
+}
+
+// plannedIngredient is a single ingredient of a step and, for automated
+// ingredients, the pump it will be dispensed from. Ingredient groups are
+// replaced by the concrete ingredient chosen for the order.
+type plannedIngredient struct {
+	Ingredient    *models.Ingredient
+	AmountInMl    int
+	Pump          *models.Pump
+	Manual        bool
+	Group         *models.Ingredient
+	DispensedInMl int
+}
+
+// buildProductionPlan scales a recipe to the ordered volume, applies boosts,
+// resolves ingredient groups and assigns every automated ingredient to a pump
+func buildProductionPlan(recipe *models.Recipe, config models.CocktailOrderConfiguration, pumps []models.Pump, ingredients []models.Ingredient) *productionPlan {
+	steps := make([]models.ProductionStep, len(recipe.ProductionSteps))
+	copy(steps, recipe.ProductionSteps)
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].StepOrder < steps[j].StepOrder
+	})
+
+	amounts := scaleAmounts(steps, orderVolume(recipe, config), boostPercent(config), boostFilter(config))
+
+	// Remaining liquid per pump, so the same pump is not over-allocated
+	// when an ingredient appears in several steps
+	remaining := make(map[int64]int, len(pumps))
+	for _, pump := range pumps {
+		remaining[pump.ID] = pump.FillingLevelInMl
+	}
+
+	tree := newIngredientTree(ingredients)
+
+	plan := &productionPlan{Glass: recipe.DefaultGlass}
+	for i, step := range steps {
+		planned := plannedStep{
+			Order:   step.StepOrder,
+			DType:   step.DType,
+			Message: step.Message,
+		}
+
+		for j, stepIngredient := range step.Ingredients {
+			amount := amounts[i][j]
+			if isLiquid(stepIngredient.Ingredient) {
+				plan.TotalAmountInMl += amount
+			}
+
+			item := plannedIngredient{
+				Ingredient: stepIngredient.Ingredient,
+				AmountInMl: amount,
+			}
+			switch {
+			case isGroup(stepIngredient.Ingredient):
+				item.Group = stepIngredient.Ingredient
+				item.Ingredient, item.Pump, item.Manual = tree.resolve(stepIngredient.Ingredient, amount, pumps, remaining)
+			case isAutomated(stepIngredient.Ingredient):
+				item.Pump = selectPump(pumps, stepIngredient.IngredientID, remaining)
+			case stepIngredient.Ingredient != nil:
+				item.Manual = stepIngredient.Ingredient.DType == "ManualIngredient"
+			}
+			if item.Pump != nil {
+				if item.Pump.DType == "ValvePump" {
+					// A valve pours slower as its bottle empties, so it is
+					// timed from the level the bottle has by this step
+					pump := *item.Pump
+					pump.FillingLevelInMl = remaining[pump.ID]
+					item.Pump = &pump
+				}
+				remaining[item.Pump.ID] -= amount
+			}
+			planned.Ingredients = append(planned.Ingredients, item)
+		}
+
+		plan.Steps = append(plan.Steps, planned)
+	}
+
+	return plan
+}
+
+// orderVolume returns the liquid volume an order should have. Without an
+// ordered amount the size of the recipe's default glass is used. Zero means
+// the recipe is made as written.
+func orderVolume(recipe *models.Recipe, config models.CocktailOrderConfiguration) int {
+	if config.AmountOrderedInMl > 0 {
+		return config.AmountOrderedInMl
+	}
+	if recipe.DefaultGlass != nil && recipe.DefaultGlass.Size > 0 {
+		return recipe.DefaultGlass.Size
+	}
+	return 0
+}
+
+// boostPercent returns the strength of boosted ingredients relative to the
+// recipe, 100 meaning no boost
+func boostPercent(config models.CocktailOrderConfiguration) int {
+	if config.Customisations != nil && config.Customisations.Boost > 0 {
+		return config.Customisations.Boost
+	}
+	if len(config.BoostIngredients) > 0 {
+		return 100 + defaultBoostPercent
+	}
+	return 100
+}
+
+// boostFilter returns whether a boostable step ingredient is boosted. If the
+// order names ingredients to boost only those are, otherwise all boostable
+// ingredients are.
+func boostFilter(config models.CocktailOrderConfiguration) func(models.ProductionStepIngredient) bool {
+	selected := make(map[int64]bool, len(config.BoostIngredients))
+	for _, id := range config.BoostIngredients {
+		selected[id] = true
+	}
+	return func(stepIngredient models.ProductionStepIngredient) bool {
+		if !stepIngredient.Boostable {
+			return false
+		}
+		return len(selected) == 0 || selected[stepIngredient.IngredientID]
+	}
+}
+
+// scaleAmounts fits the step ingredients to volume ml and returns the amount
+// of every ingredient indexed by step and ingredient.
+//
+// Each ingredient follows the volume change by its Scale factor: 1 scales
+// fully, 0 keeps the amount from the recipe. Boosted ingredients are then
+// increased by boost percent and the other scaling liquids give up the same
+// amount, so the total volume stays the same.
+func scaleAmounts(steps []models.ProductionStep, volume int, boost int, boosted func(models.ProductionStepIngredient) bool) [][]int {
+	recipeTotal := 0
+	for _, step := range steps {
+		for _, stepIngredient := range step.Ingredients {
+			if isLiquid(stepIngredient.Ingredient) {
+				recipeTotal += stepIngredient.Amount
+			}
+		}
+	}
+
+	volumeFactor := 1.0
+	if volume > 0 && recipeTotal > 0 {
+		volumeFactor = float64(volume) / float64(recipeTotal)
+	}
+	if volume <= 0 {
+		volume = recipeTotal
+	}
+
+	type liquidRef struct{ step, index int }
+	var fixed, boostedRefs, adjustable []liquidRef
+	exact := make([][]float64, len(steps))
+	amounts := make([][]int, len(steps))
+
+	for i, step := range steps {
+		exact[i] = make([]float64, len(step.Ingredients))
+		amounts[i] = make([]int, len(step.Ingredients))
+		for j, stepIngredient := range step.Ingredients {
+			scale := math.Max(0, math.Min(1, stepIngredient.Scale))
+			amount := float64(stepIngredient.Amount) * (1 + (volumeFactor-1)*scale)
+
+			if !isLiquid(stepIngredient.Ingredient) {
+				amounts[i][j] = int(math.Round(amount))
+				if amounts[i][j] == 0 && stepIngredient.Amount > 0 {
+					amounts[i][j] = 1
+				}
+				continue
+			}
+
+			ref := liquidRef{i, j}
+			switch {
+			case boost != 100 && boosted(stepIngredient):
+				amount *= float64(boost) / 100
+				boostedRefs = append(boostedRefs, ref)
+			case scale > 0:
+				adjustable = append(adjustable, ref)
+			default:
+				fixed = append(fixed, ref)
+			}
+			exact[i][j] = amount
+		}
+	}
+
+	sum := func(refs []liquidRef) float64 {
+		total := 0.0
+		for _, ref := range refs {
+			total += exact[ref.step][ref.index]
+		}
+		return total
+	}
+	resize := func(refs []liquidRef, target float64) {
+		current := sum(refs)
+		if current <= 0 {
+			return
+		}
+		factor := math.Max(0, target) / current
+		for _, ref := range refs {
+			exact[ref.step][ref.index] *= factor
+		}
+	}
+
+	// Let the non boosted liquids absorb the difference. If they cannot,
+	// the boosted ones have to shrink as well.
+	fixedTotal := sum(fixed)
+	boostedTotal := sum(boostedRefs)
+	if rest := float64(volume) - fixedTotal - boostedTotal; rest >= 0 && sum(adjustable) > 0 {
+		resize(adjustable, rest)
+	} else {
+		resize(adjustable, 0)
+		resize(boostedRefs, float64(volume)-fixedTotal)
+	}
+
+	// Round and put the rounding error on the largest scaling liquid so the
+	// total matches exactly
+	liquids := append(append(append([]liquidRef{}, fixed...), boostedRefs...), adjustable...)
+	total := 0
+	for _, ref := range liquids {
+		amounts[ref.step][ref.index] = int(math.Round(exact[ref.step][ref.index]))
+		total += amounts[ref.step][ref.index]
+	}
+
+	var largest *liquidRef
+	for _, refs := range [][]liquidRef{adjustable, boostedRefs} {
+		for k := range refs {
+			ref := &refs[k]
+			if largest == nil || amounts[ref.step][ref.index] > amounts[largest.step][largest.index] {
+				largest = ref
+			}
+		}
+		if largest != nil {
+			break
+		}
+	}
+	if largest != nil && total > 0 {
+		corrected := amounts[largest.step][largest.index] + volume - total
+		if corrected >= 0 {
+			amounts[largest.step][largest.index] = corrected
+		}
+	}
+
+	return amounts
+}
+
+// resolvedGroups lists the concrete ingredient chosen for every ingredient
+// group of the plan
+func (p *productionPlan) resolvedGroups() []models.ResolvedIngredientGroup {
+	var resolved []models.ResolvedIngredientGroup
+	for _, step := range p.Steps {
+		for _, item := range step.Ingredients {
+			if item.Group == nil || item.Ingredient == nil || item.Ingredient == item.Group {
+				continue
+			}
+			resolved = append(resolved, models.ResolvedIngredientGroup{
+				GroupID:        item.Group.ID,
+				GroupName:      item.Group.Name,
+				IngredientID:   item.Ingredient.ID,
+				IngredientName: item.Ingredient.Name,
+			})
+		}
+	}
+	return resolved
+}
+
+// orderIngredients sums up the planned and dispensed amount of every
+// ingredient of the plan for the order history
+func (p *productionPlan) orderIngredients() []models.OrderIngredient {
+	ingredients := []models.OrderIngredient{}
+	index := make(map[int64]int)
+	for _, step := range p.Steps {
+		for _, item := range step.Ingredients {
+			if item.Ingredient == nil {
+				continue
+			}
+			i, ok := index[item.Ingredient.ID]
+			if !ok {
+				unit := item.Ingredient.Unit
+				if unit == "" {
+					unit = "ml"
+				}
+				i = len(ingredients)
+				index[item.Ingredient.ID] = i
+				ingredients = append(ingredients, models.OrderIngredient{
+					IngredientID:   item.Ingredient.ID,
+					IngredientName: item.Ingredient.Name,
+					Unit:           unit,
+				})
+			}
+			ingredients[i].Amount += item.AmountInMl
+			ingredients[i].Dispensed += item.DispensedInMl
+		}
+	}
+	return ingredients
+}
+
+// pumpUsage returns the amount dispensed by every pump of the plan
+func (p *productionPlan) pumpUsage() map[int64]int {
+	usage := make(map[int64]int)
+	for _, step := range p.Steps {
+		for _, item := range step.Ingredients {
+			if item.Pump != nil && item.DispensedInMl > 0 {
+				usage[item.Pump.ID] += item.DispensedInMl
+			}
+		}
+	}
+	return usage
+}
+
+// waitsForBartender reports whether the step pauses production until the
+// bartender continues it, for a written instruction or manual ingredients
+func (s plannedStep) waitsForBartender() bool {
+	if s.DType == "WrittenInstruction" {
+		return true
+	}
+	for _, item := range s.Ingredients {
+		if item.Pump == nil && item.Ingredient != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dispensedInMl returns the amount that ended up in the glass
+func (p *productionPlan) dispensedInMl() int {
+	total := 0
+	for _, step := range p.Steps {
+		for _, item := range step.Ingredients {
+			total += item.DispensedInMl
+		}
+	}
+	return total
+}
+
+// ingredientTree indexes ingredients by the group they belong to
+type ingredientTree map[int64][]*models.Ingredient
+
+// newIngredientTree builds the group tree from all known ingredients
+func newIngredientTree(ingredients []models.Ingredient) ingredientTree {
+	tree := make(ingredientTree)
+	for i := range ingredients {
+		ingredient := &ingredients[i]
+		if ingredient.ParentGroupID != nil {
+			tree[*ingredient.ParentGroupID] = append(tree[*ingredient.ParentGroupID], ingredient)
+		}
+	}
+	return tree
+}
+
+// leaves returns all concrete ingredients below a group, walking nested groups
+func (t ingredientTree) leaves(groupID int64) []*models.Ingredient {
+	var leaves []*models.Ingredient
+	visited := map[int64]bool{groupID: true}
+	pending := []int64{groupID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		for _, child := range t[id] {
+			if !isGroup(child) {
+				leaves = append(leaves, child)
+				continue
+			}
+			if !visited[child.ID] {
+				visited[child.ID] = true
+				pending = append(pending, child.ID)
+			}
+		}
+	}
+	return leaves
+}
+
+// resolve picks the concrete ingredient used for a group. A child on a pump
+// with enough liquid is preferred, then a child in the bar that can be added
+// by hand. Failing both, a child on a pump that runs short is used so the
+// shortage shows up in the feasibility report. If nothing matches the group
+// itself is returned unresolved.
+func (t ingredientTree) resolve(group *models.Ingredient, amountMl int, pumps []models.Pump, remaining map[int64]int) (*models.Ingredient, *models.Pump, bool) {
+	leaves := t.leaves(group.ID)
+
+	var shortIngredient *models.Ingredient
+	var shortPump *models.Pump
+	var bestIngredient *models.Ingredient
+	var bestPump *models.Pump
+	for _, leaf := range leaves {
+		if !isAutomated(leaf) {
+			continue
+		}
+		pump := selectPump(pumps, leaf.ID, remaining)
+		if pump == nil {
+			continue
+		}
+		if remaining[pump.ID] >= amountMl {
+			if bestPump == nil || remaining[pump.ID] > remaining[bestPump.ID] {
+				bestIngredient, bestPump = leaf, pump
+			}
+		} else if shortPump == nil || remaining[pump.ID] > remaining[shortPump.ID] {
+			shortIngredient, shortPump = leaf, pump
+		}
+	}
+	if bestPump != nil {
+		return bestIngredient, bestPump, false
+	}
+
+	for _, leaf := range leaves {
+		if leaf.InBar != nil && *leaf.InBar {
+			return leaf, nil, true
+		}
+	}
+
+	if shortPump != nil {
+		return shortIngredient, shortPump, false
+	}
+	return group, nil, false
+}
+
+// selectPump returns the completed pump loaded with the ingredient that has
+// the most liquid left
+func selectPump(pumps []models.Pump, ingredientID int64, remaining map[int64]int) *models.Pump {
+	var selected *models.Pump
+	for i := range pumps {
+		pump := &pumps[i]
+		if !pump.Completed || pump.CurrentIngredientID == nil || *pump.CurrentIngredientID != ingredientID {
+			continue
+		}
+		if selected == nil || remaining[pump.ID] > remaining[selected.ID] {
+			selected = pump
+		}
+	}
+	return selected
+}
+
+// feasibilityReport checks a production plan against pump filling levels and
+// the ingredients available in the bar. The report lists the amount of every
+// ingredient the order needs so the plan can be previewed.
+func (p *productionPlan) feasibilityReport(recipeID int64) *models.FeasibilityReport {
+	report := &models.FeasibilityReport{
+		Feasible:            true,
+		RecipeID:            recipeID,
+		MissingIngredients:  []string{},
+		InsufficientPumps:   []models.InsufficientPump{},
+		RequiredIngredients: []models.RequiredIngredient{},
+		TotalAmountInMl:     p.TotalAmountInMl,
+		Message:             "Recipe is feasible",
+	}
+
+	if len(p.Steps) == 0 {
+		report.Feasible = false
+		report.Message = "Recipe has no production steps"
+		return report
+	}
+
+	required := make(map[int64]int)
+	var pumpOrder []*models.Pump
+	pumpIngredient := make(map[int64]int)
+	missing := make(map[string]bool)
+
+	// Per ingredient totals for the order preview, indexed by ingredient ID
+	ingredientIndex := make(map[int64]int)
+	requiredIngredient := func(ingredient *models.Ingredient) *models.RequiredIngredient {
+		index, ok := ingredientIndex[ingredient.ID]
+		if !ok {
+			index = len(report.RequiredIngredients)
+			ingredientIndex[ingredient.ID] = index
+			report.RequiredIngredients = append(report.RequiredIngredients, models.RequiredIngredient{Ingredient: ingredient})
+		}
+		return &report.RequiredIngredients[index]
+	}
+
+	for _, step := range p.Steps {
+		// Pumps of a step run in parallel, so the slowest one determines
+		// how long the step takes
+		var stepDuration time.Duration
+		for _, item := range step.Ingredients {
+			ingredient := item.Ingredient
+			if ingredient == nil {
+				continue
+			}
+
+			needed := requiredIngredient(ingredient)
+			needed.AmountRequired += item.AmountInMl
+
+			switch {
+			case item.Pump != nil:
+				if _, seen := required[item.Pump.ID]; !seen {
+					pumpOrder = append(pumpOrder, item.Pump)
+				}
+				required[item.Pump.ID] += item.AmountInMl
+				pumpIngredient[item.Pump.ID] = ingredientIndex[ingredient.ID]
+				if duration, err := pumpRunDuration(item.Pump, ingredient, item.AmountInMl); err == nil && duration > stepDuration {
+					stepDuration = duration
+				}
+
+			case item.Manual:
+				if ingredient.InBar == nil || !*ingredient.InBar {
+					missing[ingredient.Name] = true
+					needed.AmountMissing += item.AmountInMl
+				}
+
+			default:
+				missing[ingredient.Name] = true
+				needed.AmountMissing += item.AmountInMl
+			}
+		}
+		report.EstimatedRunTimeMs += stepDuration.Milliseconds()
+	}
+
+	for _, step := range p.Steps {
+		for _, item := range step.Ingredients {
+			if item.Ingredient != nil && missing[item.Ingredient.Name] {
+				report.MissingIngredients = append(report.MissingIngredients, item.Ingredient.Name)
+				delete(missing, item.Ingredient.Name)
+			}
+		}
+	}
+
+	for _, pump := range pumpOrder {
+		// Empty pumps block the order even if only a tiny amount is needed
+		if required[pump.ID] <= pump.FillingLevelInMl && pump.FillingLevelInMl > 0 {
+			continue
+		}
+		shortage := models.InsufficientPump{
+			PumpID:            pump.ID,
+			PumpName:          pumpDisplayName(pump),
+			RequiredInMl:      required[pump.ID],
+			AvailableInMl:     pump.FillingLevelInMl,
+			AmountMissingInMl: required[pump.ID] - pump.FillingLevelInMl,
+		}
+		if pump.CurrentIngredient != nil {
+			shortage.IngredientName = pump.CurrentIngredient.Name
+		}
+		report.InsufficientPumps = append(report.InsufficientPumps, shortage)
+		report.RequiredIngredients[pumpIngredient[pump.ID]].AmountMissing += shortage.AmountMissingInMl
+	}
+
+	if len(report.MissingIngredients) > 0 || len(report.InsufficientPumps) > 0 {
+		report.Feasible = false
+		report.Message = fmt.Sprintf("%d ingredient(s) missing, %d pump(s) with insufficient liquid",
+			len(report.MissingIngredients), len(report.InsufficientPumps))
+	}
+
+	return report
+}
+
+// pumpRunDuration estimates how long a pump needs to dispense amountMl
+func pumpRunDuration(pump *models.Pump, ingredient *models.Ingredient, amountMl int) (time.Duration, error) {
+	switch pump.DType {
+	case "DcPump":
+		if pump.TimePerClInMs == nil {
+			return 0, errors.New("pump is not fully configured")
+		}
+		ms := float64(amountMl) / 10 * float64(*pump.TimePerClInMs) * pumpTimeMultiplier(ingredient)
+		return time.Duration(ms) * time.Millisecond, nil
+
+	case "ValvePump":
+		flow, err := newValveFlow(pump)
+		if err != nil {
+			return 0, err
+		}
+		seconds := flow.pourTime(float64(pump.FillingLevelInMl), float64(amountMl)).Seconds() * pumpTimeMultiplier(ingredient)
+		return time.Duration(seconds * float64(time.Second)), nil
+
+	case "StepperPump":
+		if pump.StepsPerCl == nil {
+			return 0, errors.New("pump is not fully configured")
+		}
+		// The motor ramps up and down, which short doses notice most
+		maxStepsPerSecond, acceleration := defaultMaxStepsPerSecond, 0
+		if pump.MaxStepsPerSecond != nil {
+			maxStepsPerSecond = *pump.MaxStepsPerSecond
+		}
+		if pump.Acceleration != nil {
+			acceleration = *pump.Acceleration
+		}
+		steps := stepperSteps(pump, ingredient, amountMl)
+		return planStepperMotion(steps, maxStepsPerSecond, acceleration).Duration(), nil
+
+	default:
+		return 0, fmt.Errorf("unsupported pump type: %s", pump.DType)
+	}
+}
+
+// stepperSteps returns the number of steps a stepper pump needs for amountMl
+func stepperSteps(pump *models.Pump, ingredient *models.Ingredient, amountMl int) int {
+	return int(float64(amountMl) / 10 * float64(*pump.StepsPerCl) * pumpTimeMultiplier(ingredient))
+}
+
+// pumpTimeMultiplier returns the ingredient specific pump time multiplier
+func pumpTimeMultiplier(ingredient *models.Ingredient) float64 {
+	if ingredient != nil && ingredient.PumpTimeMultiplier != nil && *ingredient.PumpTimeMultiplier > 0 {
+		return *ingredient.PumpTimeMultiplier
+	}
+	return 1.0
+}
+
+// isAutomated reports whether an ingredient is dispensed by a pump
+func isAutomated(ingredient *models.Ingredient) bool {
+	return ingredient != nil && ingredient.DType == "AutomatedIngredient"
+}
+
+// isGroup reports whether an ingredient is a group of other ingredients
+func isGroup(ingredient *models.Ingredient) bool {
+	return ingredient != nil && ingredient.DType == "IngredientGroup"
+}
+
+// isLiquid reports whether an ingredient amount is measured in ml and
+// therefore takes part in volume scaling
+func isLiquid(ingredient *models.Ingredient) bool {
+	if ingredient == nil {
+		return false
+	}
+	if ingredient.DType == "AutomatedIngredient" {
+		return true
+	}
+	return ingredient.Unit == "" || ingredient.Unit == "ml"
+}
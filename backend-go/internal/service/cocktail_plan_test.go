@@ -0,0 +1,459 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+func TestPumpRunDuration(t *testing.T) {
+	multiplier := 1.5
+	tests := []struct {
+		name       string
+		pump       models.Pump
+		ingredient *models.Ingredient
+		amountMl   int
+		want       time.Duration
+	}{
+		{
+			name:     "DC pump",
+			pump:     models.Pump{DType: "DcPump", TimePerClInMs: intPtr(1000)},
+			amountMl: 20,
+			want:     2 * time.Second,
+		},
+		{
+			name:       "DC pump with pump time multiplier",
+			pump:       models.Pump{DType: "DcPump", TimePerClInMs: intPtr(1000)},
+			ingredient: &models.Ingredient{PumpTimeMultiplier: &multiplier},
+			amountMl:   20,
+			want:       3 * time.Second,
+		},
+		{
+			name:     "valve pump slowing down as the bottle empties",
+			pump:     models.Pump{DType: "ValvePump", ValveFlowMlPerSec: floatPtr(10), ValveReferenceLevelInMl: intPtr(400), FillingLevelInMl: 400},
+			amountMl: 175,
+			want:     20 * time.Second,
+		},
+		{
+			name:       "valve pump with pump time multiplier",
+			pump:       models.Pump{DType: "ValvePump", ValveFlowMlPerSec: floatPtr(10), ValveReferenceLevelInMl: intPtr(400), FillingLevelInMl: 400},
+			ingredient: &models.Ingredient{PumpTimeMultiplier: &multiplier},
+			amountMl:   175,
+			want:       30 * time.Second,
+		},
+		{
+			name:     "stepper without acceleration",
+			pump:     models.Pump{DType: "StepperPump", StepsPerCl: intPtr(100), MaxStepsPerSecond: intPtr(1000)},
+			amountMl: 10,
+			want:     100 * time.Millisecond,
+		},
+		{
+			name:     "stepper reaching full speed",
+			pump:     models.Pump{DType: "StepperPump", StepsPerCl: intPtr(100), MaxStepsPerSecond: intPtr(1000), Acceleration: intPtr(10000)},
+			amountMl: 10,
+			want:     200 * time.Millisecond,
+		},
+		{
+			name:     "short stepper dose turning around halfway",
+			pump:     models.Pump{DType: "StepperPump", StepsPerCl: intPtr(100), MaxStepsPerSecond: intPtr(1000), Acceleration: intPtr(2500)},
+			amountMl: 10,
+			want:     400 * time.Millisecond,
+		},
+		{
+			name:     "stepper with the default speed",
+			pump:     models.Pump{DType: "StepperPump", StepsPerCl: intPtr(100)},
+			amountMl: 50,
+			want:     500 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pumpRunDuration(&tt.pump, tt.ingredient, tt.amountMl)
+			if err != nil {
+				t.Fatalf("pumpRunDuration: %v", err)
+			}
+			if diff := got - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("pumpRunDuration = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// liquid returns a step ingredient measured in ml
+func liquid(id int64, amount int, scale float64, boostable bool) models.ProductionStepIngredient {
+	return models.ProductionStepIngredient{
+		IngredientID: id,
+		Ingredient:   &models.Ingredient{ID: id, DType: "AutomatedIngredient"},
+		Amount:       amount,
+		Scale:        scale,
+		Boostable:    boostable,
+	}
+}
+
+func TestScaleAmounts(t *testing.T) {
+	pieces := models.ProductionStepIngredient{
+		IngredientID: 9,
+		Ingredient:   &models.Ingredient{ID: 9, DType: "ManualIngredient", Unit: "pcs"},
+		Amount:       2,
+		Scale:        1,
+	}
+	allBoosted := func(stepIngredient models.ProductionStepIngredient) bool {
+		return stepIngredient.Boostable
+	}
+
+	tests := []struct {
+		name   string
+		steps  []models.ProductionStep
+		volume int
+		boost  int
+		want   [][]int
+	}{
+		{
+			name:  "recipe as written",
+			steps: []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 40, 1, false), liquid(2, 60, 1, false)}}},
+			boost: 100,
+			want:  [][]int{{40, 60}},
+		},
+		{
+			name: "scaled to the volume across steps",
+			steps: []models.ProductionStep{
+				{Ingredients: []models.ProductionStepIngredient{liquid(1, 40, 1, false)}},
+				{Ingredients: []models.ProductionStepIngredient{liquid(2, 60, 1, false)}},
+			},
+			volume: 200,
+			boost:  100,
+			want:   [][]int{{80}, {120}},
+		},
+		{
+			name:   "fixed ingredient keeps its amount",
+			steps:  []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 20, 0, false), liquid(2, 80, 1, false)}}},
+			volume: 200,
+			boost:  100,
+			want:   [][]int{{20, 180}},
+		},
+		{
+			name:   "pieces scale but do not count as volume",
+			steps:  []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 100, 1, false), pieces}}},
+			volume: 200,
+			boost:  100,
+			want:   [][]int{{200, 4}},
+		},
+		{
+			name:   "rounding error goes to the largest liquid",
+			steps:  []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 1, 1, false), liquid(2, 1, 1, false), liquid(3, 1, 1, false)}}},
+			volume: 10,
+			boost:  100,
+			want:   [][]int{{4, 3, 3}},
+		},
+		{
+			name:   "boost taken from the other liquids",
+			steps:  []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 40, 1, true), liquid(2, 60, 1, false)}}},
+			volume: 100,
+			boost:  125,
+			want:   [][]int{{50, 50}},
+		},
+		{
+			name:   "boost larger than the other liquids",
+			steps:  []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 50, 1, true), liquid(2, 50, 1, false)}}},
+			volume: 100,
+			boost:  300,
+			want:   [][]int{{100, 0}},
+		},
+		{
+			name:   "boost does not touch fixed ingredients",
+			steps:  []models.ProductionStep{{Ingredients: []models.ProductionStepIngredient{liquid(1, 40, 1, true), liquid(2, 20, 0, false), liquid(3, 40, 1, false)}}},
+			volume: 100,
+			boost:  150,
+			want:   [][]int{{60, 20, 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scaleAmounts(tt.steps, tt.volume, tt.boost, allBoosted)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("scaleAmounts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// plannedItem summarises a planned ingredient for comparison
+type plannedItem struct {
+	ingredientID int64
+	amountMl     int
+	pumpID       int64
+	manual       bool
+}
+
+func TestBuildProductionPlan(t *testing.T) {
+	inBar := true
+	groupID := int64(10)
+	ingredients := []models.Ingredient{
+		{ID: 1, DType: "AutomatedIngredient", Name: "Gin"},
+		{ID: 2, DType: "AutomatedIngredient", Name: "Tonic"},
+		{ID: 3, DType: "AutomatedIngredient", Name: "Lime juice", ParentGroupID: &groupID},
+		{ID: 4, DType: "AutomatedIngredient", Name: "Lemon juice", ParentGroupID: &groupID},
+		{ID: 5, DType: "ManualIngredient", Name: "Lime cordial", ParentGroupID: &groupID, InBar: &inBar},
+		{ID: 10, DType: "IngredientGroup", Name: "Citrus"},
+		{ID: 11, DType: "IngredientGroup", Name: "Bitters"},
+	}
+	ingredient := func(id int64) *models.Ingredient {
+		for i := range ingredients {
+			if ingredients[i].ID == id {
+				return &ingredients[i]
+			}
+		}
+		return nil
+	}
+	step := func(order int, items ...models.ProductionStepIngredient) models.ProductionStep {
+		for i := range items {
+			items[i].Ingredient = ingredient(items[i].IngredientID)
+		}
+		return models.ProductionStep{StepOrder: order, DType: "AddIngredients", Ingredients: items}
+	}
+	pump := func(id int64, ingredientID int64, levelMl int) models.Pump {
+		return models.Pump{ID: id, DType: "DcPump", Completed: true, CurrentIngredientID: &ingredientID, FillingLevelInMl: levelMl}
+	}
+	unfinished := pump(4, 1, 1000)
+	unfinished.Completed = false
+
+	tests := []struct {
+		name   string
+		recipe models.Recipe
+		config models.CocktailOrderConfiguration
+		pumps  []models.Pump
+		want   [][]plannedItem
+	}{
+		{
+			name: "steps in order, sized to the default glass",
+			recipe: models.Recipe{
+				DefaultGlass:    &models.Glass{Size: 200},
+				ProductionSteps: []models.ProductionStep{step(2, liquid(2, 60, 1, false)), step(1, liquid(1, 40, 1, false))},
+			},
+			pumps: []models.Pump{pump(1, 1, 500), pump(2, 2, 500)},
+			want:  [][]plannedItem{{{1, 80, 1, false}}, {{2, 120, 2, false}}},
+		},
+		{
+			name: "ordered amount wins over the glass",
+			recipe: models.Recipe{
+				DefaultGlass:    &models.Glass{Size: 200},
+				ProductionSteps: []models.ProductionStep{step(1, liquid(1, 40, 1, false), liquid(2, 60, 1, false))},
+			},
+			config: models.CocktailOrderConfiguration{AmountOrderedInMl: 50},
+			pumps:  []models.Pump{pump(1, 1, 500), pump(2, 2, 500)},
+			want:   [][]plannedItem{{{1, 20, 1, false}, {2, 30, 2, false}}},
+		},
+		{
+			name:   "named boost ingredients default to 25 percent",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(1, 40, 1, true), liquid(2, 60, 1, true))}},
+			config: models.CocktailOrderConfiguration{BoostIngredients: []int64{1}},
+			pumps:  []models.Pump{pump(1, 1, 500), pump(2, 2, 500)},
+			want:   [][]plannedItem{{{1, 50, 1, false}, {2, 50, 2, false}}},
+		},
+		{
+			name:   "custom boost applies to all boostable ingredients",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(1, 40, 1, true), liquid(2, 60, 1, false))}},
+			config: models.CocktailOrderConfiguration{Customisations: &models.OrderCustomisations{Boost: 150}},
+			pumps:  []models.Pump{pump(1, 1, 500), pump(2, 2, 500)},
+			want:   [][]plannedItem{{{1, 60, 1, false}, {2, 40, 2, false}}},
+		},
+		{
+			name:   "fullest completed pump is used",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(1, 40, 1, false))}},
+			pumps:  []models.Pump{pump(1, 1, 100), pump(2, 1, 300), unfinished},
+			want:   [][]plannedItem{{{1, 40, 2, false}}},
+		},
+		{
+			name: "pump used in an earlier step counts as emptied",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{
+				step(1, liquid(1, 40, 1, false)),
+				step(2, liquid(1, 40, 1, false)),
+			}},
+			pumps: []models.Pump{pump(1, 1, 100), pump(2, 1, 80)},
+			want:  [][]plannedItem{{{1, 40, 1, false}}, {{1, 40, 2, false}}},
+		},
+		{
+			name:   "group resolves to the fullest pump with enough liquid",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(10, 30, 1, false))}},
+			pumps:  []models.Pump{pump(3, 3, 200), pump(4, 4, 400)},
+			want:   [][]plannedItem{{{4, 30, 4, false}}},
+		},
+		{
+			name:   "group skips a pump running short",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(10, 30, 1, false))}},
+			pumps:  []models.Pump{pump(3, 3, 30), pump(4, 4, 20)},
+			want:   [][]plannedItem{{{3, 30, 3, false}}},
+		},
+		{
+			name:   "group falls back to the bar",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(10, 30, 1, false))}},
+			pumps:  []models.Pump{pump(3, 3, 20)},
+			want:   [][]plannedItem{{{5, 30, 0, true}}},
+		},
+		{
+			name:   "group without any match stays unresolved",
+			recipe: models.Recipe{ProductionSteps: []models.ProductionStep{step(1, liquid(11, 30, 1, false))}},
+			pumps:  []models.Pump{pump(3, 3, 200)},
+			want:   [][]plannedItem{{{11, 30, 0, false}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := buildProductionPlan(&tt.recipe, tt.config, tt.pumps, ingredients)
+
+			var got [][]plannedItem
+			for _, step := range plan.Steps {
+				var items []plannedItem
+				for _, item := range step.Ingredients {
+					summary := plannedItem{ingredientID: item.Ingredient.ID, amountMl: item.AmountInMl, manual: item.Manual}
+					if item.Pump != nil {
+						summary.pumpID = item.Pump.ID
+					}
+					items = append(items, summary)
+				}
+				got = append(got, items)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("plan = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeasibilityReport(t *testing.T) {
+	inBar, notInBar := true, false
+	gin := &models.Ingredient{ID: 1, DType: "AutomatedIngredient", Name: "Gin"}
+	tonic := &models.Ingredient{ID: 2, DType: "AutomatedIngredient", Name: "Tonic"}
+	mint := &models.Ingredient{ID: 3, DType: "ManualIngredient", Name: "Mint", InBar: &inBar}
+	sugar := &models.Ingredient{ID: 4, DType: "ManualIngredient", Name: "Sugar", InBar: &notInBar}
+	pump := func(id int64, levelMl int) *models.Pump {
+		return &models.Pump{ID: id, DType: "DcPump", TimePerClInMs: intPtr(1000), FillingLevelInMl: levelMl}
+	}
+	pumped := func(ingredient *models.Ingredient, amountMl int, pump *models.Pump) plannedIngredient {
+		return plannedIngredient{Ingredient: ingredient, AmountInMl: amountMl, Pump: pump}
+	}
+	ginPump, tonicPump := pump(1, 100), pump(2, 100)
+
+	tests := []struct {
+		name         string
+		steps        []plannedStep
+		feasible     bool
+		runTimeMs    int64
+		missing      []string
+		insufficient []models.InsufficientPump
+		required     map[int64][2]int // ingredient ID to required and missing ml
+	}{
+		{
+			name:     "no steps",
+			feasible: false,
+			required: map[int64][2]int{},
+		},
+		{
+			name: "steps take as long as their slowest pump",
+			steps: []plannedStep{
+				{Ingredients: []plannedIngredient{pumped(gin, 40, ginPump), pumped(tonic, 60, tonicPump)}},
+				{Ingredients: []plannedIngredient{pumped(gin, 20, ginPump)}},
+			},
+			feasible:  true,
+			runTimeMs: 8000,
+			required:  map[int64][2]int{1: {60, 0}, 2: {60, 0}},
+		},
+		{
+			name: "level below the amount",
+			steps: []plannedStep{
+				{Ingredients: []plannedIngredient{pumped(gin, 80, ginPump)}},
+				{Ingredients: []plannedIngredient{pumped(gin, 40, ginPump)}},
+			},
+			feasible:  false,
+			runTimeMs: 12000,
+			insufficient: []models.InsufficientPump{
+				{PumpID: 1, PumpName: "#1", RequiredInMl: 120, AvailableInMl: 100, AmountMissingInMl: 20},
+			},
+			required: map[int64][2]int{1: {120, 20}},
+		},
+		{
+			name:     "empty pump blocks the order",
+			steps:    []plannedStep{{Ingredients: []plannedIngredient{pumped(gin, 0, pump(3, 0))}}},
+			feasible: false,
+			insufficient: []models.InsufficientPump{
+				{PumpID: 3, PumpName: "#3"},
+			},
+			required: map[int64][2]int{1: {0, 0}},
+		},
+		{
+			name: "manual ingredients must be in the bar",
+			steps: []plannedStep{{Ingredients: []plannedIngredient{
+				{Ingredient: mint, AmountInMl: 2, Manual: true},
+				{Ingredient: sugar, AmountInMl: 1, Manual: true},
+			}}},
+			feasible: false,
+			missing:  []string{"Sugar"},
+			required: map[int64][2]int{3: {2, 0}, 4: {1, 1}},
+		},
+		{
+			name: "automated ingredient without a pump",
+			steps: []plannedStep{
+				{Ingredients: []plannedIngredient{{Ingredient: tonic, AmountInMl: 60}}},
+				{Ingredients: []plannedIngredient{{Ingredient: tonic, AmountInMl: 20}}},
+			},
+			feasible: false,
+			missing:  []string{"Tonic"},
+			required: map[int64][2]int{2: {80, 80}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &productionPlan{Steps: tt.steps}
+			report := plan.feasibilityReport(7)
+
+			if report.Feasible != tt.feasible {
+				t.Errorf("Feasible = %v, want %v (%s)", report.Feasible, tt.feasible, report.Message)
+			}
+			if report.RecipeID != 7 {
+				t.Errorf("RecipeID = %d, want 7", report.RecipeID)
+			}
+			if report.EstimatedRunTimeMs != tt.runTimeMs {
+				t.Errorf("EstimatedRunTimeMs = %d, want %d", report.EstimatedRunTimeMs, tt.runTimeMs)
+			}
+			if fmt.Sprint(report.MissingIngredients) != fmt.Sprint(tt.missing) {
+				t.Errorf("MissingIngredients = %v, want %v", report.MissingIngredients, tt.missing)
+			}
+			if fmt.Sprint(report.InsufficientPumps) != fmt.Sprint(tt.insufficient) {
+				t.Errorf("InsufficientPumps = %+v, want %+v", report.InsufficientPumps, tt.insufficient)
+			}
+
+			required := make(map[int64][2]int)
+			for _, ingredient := range report.RequiredIngredients {
+				required[ingredient.Ingredient.ID] = [2]int{ingredient.AmountRequired, ingredient.AmountMissing}
+			}
+			if fmt.Sprint(required) != fmt.Sprint(tt.required) {
+				t.Errorf("RequiredIngredients = %v, want %v", required, tt.required)
+			}
+		})
+	}
+}
+
+func TestBuildProductionPlanValveLevel(t *testing.T) {
+	ginID := int64(1)
+	gin := models.Ingredient{ID: ginID, DType: "AutomatedIngredient", Name: "Gin"}
+	valve := models.Pump{ID: 1, DType: "ValvePump", Completed: true, CurrentIngredientID: &ginID, FillingLevelInMl: 400}
+	recipe := models.Recipe{ProductionSteps: []models.ProductionStep{
+		{StepOrder: 1, Ingredients: []models.ProductionStepIngredient{{IngredientID: ginID, Ingredient: &gin, Amount: 100, Scale: 1}}},
+		{StepOrder: 2, Ingredients: []models.ProductionStepIngredient{{IngredientID: ginID, Ingredient: &gin, Amount: 50, Scale: 1}}},
+	}}
+
+	plan := buildProductionPlan(&recipe, models.CocktailOrderConfiguration{}, []models.Pump{valve}, []models.Ingredient{gin})
+
+	// Each step times the valve from the level left by the steps before it
+	for i, want := range []int{400, 300} {
+		if got := plan.Steps[i].Ingredients[0].Pump.FillingLevelInMl; got != want {
+			t.Errorf("step %d plans with a level of %d ml, want %d", i+1, got, want)
+		}
+	}
+}
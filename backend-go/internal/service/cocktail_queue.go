@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// GetQueue returns the queued orders with their estimated start and finish times
+func (s *CocktailService) GetQueue() ([]models.QueuedOrder, error) {
+	s.mu.RLock()
+	start, dispatched := s.nextAvailableAt(), s.dispatched
+	s.mu.RUnlock()
+
+	return s.queueSnapshot(start, dispatched)
+}
+
+// CancelQueuedOrder removes an order from the queue before it is produced.
+// The lock keeps dispatchNext from starting the order at the same time; an
+// order that was already started is no longer queued.
+func (s *CocktailService) CancelQueuedOrder(id int64, userID int64, isAdmin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, err := s.queueRepo.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to find queued order: %w", err)
+	}
+	if order == nil || order.ID == s.dispatched {
+		return errors.New("queued order not found")
+	}
+
+	if order.UserID != userID && !isAdmin {
+		return errors.New("you can only cancel your own orders")
+	}
+
+	if err := s.queueRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to cancel queued order: %w", err)
+	}
+
+	s.publishProgress()
+	return nil
+}
+
+// MoveQueuedOrder moves an order to a new zero based position in the queue.
+// The order takes over the priority of its new neighbours so it stays where
+// it was put.
+func (s *CocktailService) MoveQueuedOrder(id int64, position int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders, err := s.queueRepo.FindAll()
+	if err != nil {
+		return fmt.Errorf("failed to get queue: %w", err)
+	}
+	orders = withoutOrder(orders, s.dispatched)
+
+	index := -1
+	for i := range orders {
+		if orders[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return errors.New("queued order not found")
+	}
+
+	orders = moveOrder(orders, index, position)
+	if err := s.queueRepo.SaveOrdering(orders); err != nil {
+		return fmt.Errorf("failed to reorder queue: %w", err)
+	}
+
+	s.publishProgress()
+	return nil
+}
+
+// moveOrder moves the order at index to a new zero based position and
+// numbers the queue again. The moved order takes the priority of the order
+// after it, or before it at the end of the queue.
+func moveOrder(orders []models.QueuedOrder, index int, position int) []models.QueuedOrder {
+	if position < 0 {
+		position = 0
+	}
+	if position > len(orders)-1 {
+		position = len(orders) - 1
+	}
+
+	moved := orders[index]
+	orders = append(orders[:index], orders[index+1:]...)
+	orders = append(orders[:position], append([]models.QueuedOrder{moved}, orders[position:]...)...)
+
+	if position < len(orders)-1 {
+		orders[position].Priority = orders[position+1].Priority
+	} else if position > 0 {
+		orders[position].Priority = orders[position-1].Priority
+	}
+	for i := range orders {
+		orders[i].Position = i
+	}
+	return orders
+}
+
+// SetQueuedOrderPriority changes the priority of a queued order. Orders with a
+// higher priority are produced first.
+func (s *CocktailService) SetQueuedOrderPriority(id int64, priority int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, err := s.queueRepo.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to find queued order: %w", err)
+	}
+	if order == nil || order.ID == s.dispatched {
+		return errors.New("queued order not found")
+	}
+
+	if err := s.queueRepo.UpdatePriority(id, priority); err != nil {
+		return fmt.Errorf("failed to update priority: %w", err)
+	}
+
+	s.publishProgress()
+	return nil
+}
+
+// dispatchNext starts the next queued order if no cocktail is being made, no
+// pumps are being cleaned and an empty glass is ready. Orders that can no
+// longer be produced are dropped with an error status.
+func (s *CocktailService) dispatchNext() {
+	for {
+		failed := s.startNext()
+		if failed == nil {
+			return
+		}
+
+		s.recordOrder(*failed, nil)
+
+		s.mu.Lock()
+		s.busy = false
+		s.dispatched = 0
+		s.mu.Unlock()
+	}
+}
+
+// startNext starts the next queued order. The order stays queued until it
+// is recorded in the history, so a restart in between produces it again.
+// An order that cannot be started is returned and keeps the production
+// busy until the caller recorded it.
+func (s *CocktailService) startNext() *models.CocktailProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.busy || !s.pumpRuntime.Available() || s.pumpRuntime.IsStopped() || s.cleaning.Running() || !s.dispensingArea.GlassReady() {
+		return nil
+	}
+
+	next, err := s.queueRepo.FindNext()
+	if err != nil {
+		log.Printf("Failed to read cocktail queue: %v", err)
+		return nil
+	}
+	if next == nil {
+		return nil
+	}
+
+	s.dispatched = next.ID
+	if err := s.startOrder(next); err != nil {
+		now := time.Now()
+		s.currentOrder = &models.CocktailProgress{
+			OrderID:     next.ID,
+			RecipeID:    next.RecipeID,
+			RecipeName:  next.RecipeName,
+			UserID:      next.UserID,
+			Username:    next.Username,
+			Status:      "error",
+			Message:     err.Error(),
+			StartedAt:   now,
+			CompletedAt: &now,
+		}
+		s.busy = true
+		s.publishProgress()
+
+		progress := *s.currentOrder
+		return &progress
+	}
+	return nil
+}
+
+// startOrder plans a queued order and starts producing it. Orders without
+// an ordered amount fill a recognised glass in the dispensing area.
+// Must be called with s.mu held.
+func (s *CocktailService) startOrder(order *models.QueuedOrder) error {
+	recipe, err := s.recipeRepo.FindByID(order.RecipeID)
+	if err != nil {
+		return fmt.Errorf("failed to find recipe: %w", err)
+	}
+	if recipe == nil {
+		return errors.New("recipe not found")
+	}
+
+	config := order.Configuration
+	glass := s.dispensingArea.DetectedGlass()
+	if glass != nil && config.AmountOrderedInMl == 0 {
+		config.AmountOrderedInMl = glass.Size
+	}
+
+	plan, feasibility, err := s.planOrder(recipe, config)
+	if err != nil {
+		return fmt.Errorf("failed to check feasibility: %w", err)
+	}
+	if !feasibility.Feasible {
+		return fmt.Errorf("recipe is not feasible: %s", feasibility.Message)
+	}
+	if glass != nil {
+		plan.Glass = glass
+	}
+
+	s.currentOrder = &models.CocktailProgress{
+		OrderID:         order.ID,
+		RecipeID:        recipe.ID,
+		RecipeName:      recipe.Name,
+		UserID:          order.UserID,
+		Username:        order.Username,
+		CurrentStep:     0,
+		TotalSteps:      len(plan.Steps),
+		Status:          "in_progress",
+		Message:         "Starting production",
+		PercentComplete: 0,
+		StartedAt:       time.Now(),
+		ResolvedGroups:  plan.resolvedGroups(),
+	}
+	s.currentEstimate = time.Duration(feasibility.EstimatedRunTimeMs) * time.Millisecond
+	s.busy = true
+	s.publishProgress()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelCurrent = cancel
+
+	go s.runOrder(ctx, plan)
+
+	return nil
+}
+
+// runOrder produces an order, waits for the glass changeover and then
+// continues with the next queued order
+func (s *CocktailService) runOrder(ctx context.Context, plan *productionPlan) {
+	s.produceCoktail(ctx, plan)
+	if plan.dispensedInMl() > 0 {
+		s.dispensingArea.MarkFilled()
+	}
+
+	s.mu.Lock()
+	s.cancelCurrent()
+	s.cancelCurrent = nil
+	progress := *s.currentOrder
+	s.mu.Unlock()
+
+	s.recordOrder(progress, plan)
+
+	time.Sleep(s.changeover)
+
+	s.mu.Lock()
+	s.busy = false
+	s.dispatched = 0
+	s.mu.Unlock()
+
+	s.dispatchNext()
+}
+
+// producing reports whether a cocktail is being made or the glass is being
+// changed after one
+func (s *CocktailService) producing() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.busy
+}
+
+// queueSnapshot returns the queue with estimated times, starting at start.
+// The dispatched order is being produced and no longer counts as queued.
+func (s *CocktailService) queueSnapshot(start time.Time, dispatched int64) ([]models.QueuedOrder, error) {
+	orders, err := s.queueRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	orders = withoutOrder(orders, dispatched)
+	estimateQueue(orders, start, s.changeover)
+
+	return orders, nil
+}
+
+// estimateQueue sets the estimated start and finish times of the queued
+// orders, produced one after the other from start with a glass changeover
+// in between
+func estimateQueue(orders []models.QueuedOrder, start time.Time, changeover time.Duration) {
+	for i := range orders {
+		startAt := start
+		finishAt := startAt.Add(time.Duration(orders[i].EstimatedDurationMs) * time.Millisecond)
+		orders[i].EstimatedStartAt = &startAt
+		orders[i].EstimatedFinishAt = &finishAt
+		start = finishAt.Add(changeover)
+	}
+}
+
+// withoutOrder removes the order with the given ID from orders
+func withoutOrder(orders []models.QueuedOrder, id int64) []models.QueuedOrder {
+	for i := range orders {
+		if orders[i].ID == id {
+			return append(orders[:i], orders[i+1:]...)
+		}
+	}
+	return orders
+}
+
+// nextAvailableAt estimates when the next queued order can start.
+// Must be called with s.mu held.
+func (s *CocktailService) nextAvailableAt() time.Time {
+	now := time.Now()
+	if !s.busy || s.currentOrder == nil {
+		return now
+	}
+
+	var next time.Time
+	if s.currentOrder.CompletedAt != nil {
+		next = s.currentOrder.CompletedAt.Add(s.changeover)
+	} else {
+		next = s.currentOrder.StartedAt.Add(s.currentEstimate + s.changeover)
+	}
+	if next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// progressUpdate is the production state at the time progress was published
+type progressUpdate struct {
+	progress   models.CocktailProgress
+	queueStart time.Time // when the next queued order can start
+	dispatched int64
+}
+
+// publishProgress pushes the current progress together with the queue to
+// all subscribers. Before the first order only the queue is published, with
+// status idle. The queue is read and sent by runProgressPublisher, so s.mu
+// is not held during the I/O. Must be called with s.mu held.
+func (s *CocktailService) publishProgress() {
+	if s.publisher == nil {
+		return
+	}
+
+	update := &progressUpdate{
+		progress:   models.CocktailProgress{Status: "idle"},
+		queueStart: s.nextAvailableAt(),
+		dispatched: s.dispatched,
+	}
+	if s.currentOrder != nil {
+		update.progress = *s.currentOrder
+	}
+	s.pendingProgress = update
+
+	select {
+	case s.progressReady <- struct{}{}:
+	default:
+	}
+}
+
+// runProgressPublisher sends the progress published by publishProgress.
+// Progress published while the previous one is still being sent replaces
+// it, subscribers only need the latest state.
+func (s *CocktailService) runProgressPublisher() {
+	for range s.progressReady {
+		s.mu.Lock()
+		update := s.pendingProgress
+		s.pendingProgress = nil
+		s.mu.Unlock()
+		if update == nil {
+			continue
+		}
+
+		queue, err := s.queueSnapshot(update.queueStart, update.dispatched)
+		if err != nil {
+			log.Printf("Failed to read cocktail queue: %v", err)
+		}
+		update.progress.Queue = queue
+
+		s.publisher.BroadcastCocktailProgress(update.progress)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+func TestMoveOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		index    int
+		position int
+		want     string // ID:priority in queue order
+	}{
+		{name: "to the front takes the priority of the next order", index: 3, position: 0, want: "[4:5 1:5 2:5 3:0]"},
+		{name: "to the end takes the priority of the previous order", index: 0, position: 3, want: "[2:5 3:0 4:0 1:0]"},
+		{name: "behind the last order", index: 0, position: 10, want: "[2:5 3:0 4:0 1:0]"},
+		{name: "before the first order", index: 2, position: -1, want: "[3:5 1:5 2:5 4:0]"},
+		{name: "into a lower priority", index: 1, position: 2, want: "[1:5 3:0 2:0 4:0]"},
+		{name: "onto its own place", index: 2, position: 2, want: "[1:5 2:5 3:0 4:0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := []models.QueuedOrder{
+				{ID: 1, Priority: 5, Position: 0},
+				{ID: 2, Priority: 5, Position: 1},
+				{ID: 3, Priority: 0, Position: 2},
+				{ID: 4, Priority: 0, Position: 3},
+			}
+
+			orders = moveOrder(orders, tt.index, tt.position)
+
+			var got []string
+			for i, order := range orders {
+				if order.Position != i {
+					t.Errorf("order %d has position %d, want %d", order.ID, order.Position, i)
+				}
+				got = append(got, fmt.Sprintf("%d:%d", order.ID, order.Priority))
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("queue = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateQueue(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	orders := []models.QueuedOrder{
+		{ID: 1, EstimatedDurationMs: 30000},
+		{ID: 2, EstimatedDurationMs: 10000},
+		{ID: 3},
+	}
+
+	estimateQueue(orders, start, 15*time.Second)
+
+	want := [][2]time.Duration{{0, 30 * time.Second}, {45 * time.Second, 55 * time.Second}, {70 * time.Second, 70 * time.Second}}
+	for i, order := range orders {
+		if got := order.EstimatedStartAt.Sub(start); got != want[i][0] {
+			t.Errorf("order %d starts after %s, want %s", order.ID, got, want[i][0])
+		}
+		if got := order.EstimatedFinishAt.Sub(start); got != want[i][1] {
+			t.Errorf("order %d finishes after %s, want %s", order.ID, got, want[i][1])
+		}
+	}
+}
+
+func TestWithoutOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		id   int64
+		want string
+	}{
+		{name: "dispatched order", id: 2, want: "[1 3]"},
+		{name: "nothing dispatched", id: 0, want: "[1 2 3]"},
+		{name: "order no longer queued", id: 7, want: "[1 2 3]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := withoutOrder([]models.QueuedOrder{{ID: 1}, {ID: 2}, {ID: 3}}, tt.id)
+
+			var got []int64
+			for _, order := range orders {
+				got = append(got, order.ID)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("queue = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextAvailableAt(t *testing.T) {
+	now := time.Now()
+	completedAt := now.Add(-2 * time.Second)
+
+	tests := []struct {
+		name     string
+		busy     bool
+		progress *models.CocktailProgress
+		estimate time.Duration
+		want     time.Duration // from now
+	}{
+		{name: "idle", want: 0},
+		{
+			name:     "order running",
+			busy:     true,
+			progress: &models.CocktailProgress{Status: "in_progress", StartedAt: now.Add(-5 * time.Second)},
+			estimate: 20 * time.Second,
+			want:     25 * time.Second,
+		},
+		{
+			name:     "glass being changed",
+			busy:     true,
+			progress: &models.CocktailProgress{Status: "completed", StartedAt: now.Add(-time.Minute), CompletedAt: &completedAt},
+			estimate: 20 * time.Second,
+			want:     8 * time.Second,
+		},
+		{
+			name:     "order taking longer than estimated",
+			busy:     true,
+			progress: &models.CocktailProgress{Status: "in_progress", StartedAt: now.Add(-time.Minute)},
+			estimate: 20 * time.Second,
+			want:     0,
+		},
+		{
+			name:     "last order finished",
+			progress: &models.CocktailProgress{Status: "completed", StartedAt: now.Add(-time.Minute), CompletedAt: &completedAt},
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &CocktailService{
+				changeover:      10 * time.Second,
+				busy:            tt.busy,
+				currentOrder:    tt.progress,
+				currentEstimate: tt.estimate,
+			}
+
+			got := s.nextAvailableAt().Sub(now)
+			if diff := got - tt.want; diff < 0 || diff > time.Second {
+				t.Errorf("next order starts after %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,35 +1,90 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
 )
 
 // CocktailService handles cocktail order operations
 type CocktailService struct {
-	recipeRepo     *repository.RecipeRepository
-	ingredientRepo *repository.IngredientRepository
-	pumpRepo       *repository.PumpRepository
-	currentOrder   *models.CocktailProgress
-	mu             sync.RWMutex
+	recipeRepo        *repository.RecipeRepository
+	ingredientRepo    *repository.IngredientRepository
+	pumpRepo          *repository.PumpRepository
+	queueRepo         *repository.CocktailQueueRepository
+	orderRepo         *repository.OrderRepository
+	pumpRuntime       *PumpRuntime
+	dispensingArea    *DispensingAreaService
+	cleaning          *PumpCleaningService
+	publisher         EventPublisher
+	changeover        time.Duration
+	wearLimits        models.TubeWearLimits
+	manualStepTimeout time.Duration
+	currentOrder      *models.CocktailProgress
+	currentEstimate   time.Duration
+	cancelCurrent     context.CancelFunc
+	interruptStep     context.CancelFunc // stops the pumps when the glass is removed
+	resume            chan struct{}
+	waitingForGlass   bool
+	busy              bool
+	dispatched        int64 // queued order being produced, until it is recorded
+	pendingProgress   *progressUpdate
+	progressReady     chan struct{}
+	mu                sync.RWMutex
 }
 
-// NewCocktailService creates a new cocktail service
+// errManualStepTimeout is returned when nobody confirmed a manual step in time
+var errManualStepTimeout = errors.New("manual step timed out")
+
+// NewCocktailService creates a new cocktail service and resumes any orders
+// left in the queue.
+// Orders are rejected when the pump runtime has no GPIO hardware available.
+// Production waits for a glass in the dispensing area and while the pumps
+// are being cleaned.
 func NewCocktailService(
+	cfg *config.Config,
 	recipeRepo *repository.RecipeRepository,
 	ingredientRepo *repository.IngredientRepository,
 	pumpRepo *repository.PumpRepository,
+	queueRepo *repository.CocktailQueueRepository,
+	orderRepo *repository.OrderRepository,
+	pumpRuntime *PumpRuntime,
+	dispensingArea *DispensingAreaService,
+	cleaning *PumpCleaningService,
+	publisher EventPublisher,
 ) *CocktailService {
-	return &CocktailService{
-		recipeRepo:     recipeRepo,
-		ingredientRepo: ingredientRepo,
-		pumpRepo:       pumpRepo,
+	s := &CocktailService{
+		recipeRepo:        recipeRepo,
+		ingredientRepo:    ingredientRepo,
+		pumpRepo:          pumpRepo,
+		queueRepo:         queueRepo,
+		orderRepo:         orderRepo,
+		pumpRuntime:       pumpRuntime,
+		dispensingArea:    dispensingArea,
+		cleaning:          cleaning,
+		publisher:         publisher,
+		changeover:        cfg.Cocktail.QueueChangeover,
+		wearLimits:        tubeWearLimits(cfg),
+		manualStepTimeout: cfg.Cocktail.ManualStepTimeout,
+		progressReady:     make(chan struct{}, 1),
 	}
+
+	pumpRuntime.OnEmergencyStop(s.abortForEmergencyStop)
+	pumpRuntime.OnRearm(func() { go s.dispatchNext() })
+	dispensingArea.OnChange(s.onDispensingAreaChange)
+	cleaning.OnFinish(func() { go s.dispatchNext() })
+	cleaning.waitForProduction(s.producing)
+
+	go s.runProgressPublisher()
+	go s.dispatchNext()
+
+	return s
 }
 
 // CheckFeasibility checks if a recipe can be made with current ingredients
@@ -42,117 +97,164 @@ func (s *CocktailService) CheckFeasibility(recipeID int64, config models.Cocktai
 		return nil, errors.New("recipe not found")
 	}
 
-	report := &models.FeasibilityReport{
-		Feasible:           true,
-		RecipeID:           recipeID,
-		MissingIngredients: []string{},
-		InsufficientPumps:  []string{},
-		Message:            "Recipe is feasible",
+	_, report, err := s.planOrder(recipe, config)
+	if err != nil {
+		return nil, err
 	}
+	return report, nil
+}
 
-	// Get all pumps
+// planOrder builds the production plan for an order and checks it against
+// the current pump and bar state
+func (s *CocktailService) planOrder(recipe *models.Recipe, config models.CocktailOrderConfiguration) (*productionPlan, *models.FeasibilityReport, error) {
 	pumps, err := s.pumpRepo.FindAll()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pumps: %w", err)
+		return nil, nil, fmt.Errorf("failed to get pumps: %w", err)
 	}
 
-	// Check if we have pumps with required ingredients
-	// This is a simplified check - full implementation would check quantities
-	if len(pumps) == 0 {
-		report.Feasible = false
-		report.Message = "No pumps configured"
-		return report, nil
-	}
-
-	// Check production steps and ingredients
-	if len(recipe.ProductionSteps) == 0 {
-		report.Feasible = false
-		report.Message = "Recipe has no production steps"
-		return report, nil
+	ingredients, err := s.ingredientRepo.FindAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get ingredients: %w", err)
 	}
 
-	// In a full implementation, we would:
-	// 1. Check each ingredient in production steps
-	// 2. Verify pumps have sufficient liquid
-	// 3. Check if manual ingredients are in bar
-	// 4. Calculate total volume needed
-
-	return report, nil
+	plan := buildProductionPlan(recipe, config, pumps, ingredients)
+	return plan, plan.feasibilityReport(recipe.ID), nil
 }
 
-// OrderCocktail starts cocktail production
-func (s *CocktailService) OrderCocktail(userID int64, username string, recipeID int64, config models.CocktailOrderConfiguration) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Check if there's already an order in progress
-	if s.currentOrder != nil && s.currentOrder.Status == "in_progress" {
-		return errors.New("another cocktail is already being made")
+// OrderCocktail adds a cocktail order to the production queue
+func (s *CocktailService) OrderCocktail(userID int64, username string, recipeID int64, config models.CocktailOrderConfiguration) (*models.QueuedOrder, error) {
+	if !s.pumpRuntime.Available() {
+		return nil, errors.New("GPIO service not available")
+	}
+	if s.pumpRuntime.IsStopped() {
+		return nil, errPumpsStopped
 	}
 
 	// Get recipe
 	recipe, err := s.recipeRepo.FindByID(recipeID)
 	if err != nil {
-		return fmt.Errorf("failed to find recipe: %w", err)
+		return nil, fmt.Errorf("failed to find recipe: %w", err)
 	}
 	if recipe == nil {
-		return errors.New("recipe not found")
+		return nil, errors.New("recipe not found")
 	}
 
 	// Check feasibility
-	feasibility, err := s.CheckFeasibility(recipeID, config)
+	_, feasibility, err := s.planOrder(recipe, config)
 	if err != nil {
-		return fmt.Errorf("failed to check feasibility: %w", err)
+		return nil, fmt.Errorf("failed to check feasibility: %w", err)
 	}
 	if !feasibility.Feasible {
-		return fmt.Errorf("recipe is not feasible: %s", feasibility.Message)
+		return nil, fmt.Errorf("recipe is not feasible: %s", feasibility.Message)
 	}
 
-	// Create progress tracker
-	s.currentOrder = &models.CocktailProgress{
-		RecipeID:        recipeID,
-		RecipeName:      recipe.Name,
-		UserID:          userID,
-		Username:        username,
-		CurrentStep:     0,
-		TotalSteps:      len(recipe.ProductionSteps),
-		Status:          "in_progress",
-		Message:         "Starting production",
-		PercentComplete: 0,
-		StartedAt:       time.Now(),
+	order := &models.QueuedOrder{
+		UserID:              userID,
+		Username:            username,
+		RecipeID:            recipe.ID,
+		RecipeName:          recipe.Name,
+		Configuration:       config,
+		EstimatedDurationMs: feasibility.EstimatedRunTimeMs,
+	}
+	if err := s.queueRepo.Create(order); err != nil {
+		return nil, fmt.Errorf("failed to queue order: %w", err)
 	}
 
-	// Start production in background
-	go s.produceCoktail(recipe, config)
+	s.mu.Lock()
+	s.publishProgress()
+	s.mu.Unlock()
 
-	return nil
+	go s.dispatchNext()
+
+	return order, nil
 }
 
-// produceCoktail simulates cocktail production
-func (s *CocktailService) produceCoktail(recipe *models.Recipe, config models.CocktailOrderConfiguration) {
-	// This is a simplified simulation
-	// Full implementation would:
-	// 1. Control actual pumps via GPIO
-	// 2. Handle manual ingredient prompts
-	// 3. Monitor production progress
-	// 4. Handle errors and cleanup
+// produceCoktail dispenses a production plan step by step. All pumps of a
+// step run at the same time. Written instructions and manual ingredients
+// pause production until the bartender continues it.
+func (s *CocktailService) produceCoktail(ctx context.Context, plan *productionPlan) {
+	totalMl, manualSteps := 0, 0
+	for _, step := range plan.Steps {
+		if step.waitsForBartender() {
+			manualSteps++
+		}
+		for _, item := range step.Ingredients {
+			if item.Pump != nil {
+				totalMl += item.AmountInMl
+			}
+		}
+	}
+	// A step done by the bartender counts as much as an average step, so
+	// the progress does not reach 100 while one is still pending
+	manualStepMl := 0
+	if len(plan.Steps) > 0 {
+		manualStepMl = max(totalMl/len(plan.Steps), 1)
+	}
+	totalMl += manualSteps * manualStepMl
 
-	totalSteps := len(recipe.ProductionSteps)
-	
-	for i := 0; i < totalSteps; i++ {
+	dispensedMl := 0
+	for i, step := range plan.Steps {
 		s.mu.Lock()
 		if s.currentOrder.Status == "cancelled" {
 			s.mu.Unlock()
 			return
 		}
-		
+
 		s.currentOrder.CurrentStep = i + 1
-		s.currentOrder.PercentComplete = ((i + 1) * 100) / totalSteps
-		s.currentOrder.Message = fmt.Sprintf("Processing step %d of %d", i+1, totalSteps)
+		s.currentOrder.Message = fmt.Sprintf("Processing step %d of %d", i+1, len(plan.Steps))
+		s.currentOrder.Pumps = nil
+		s.publishProgress()
 		s.mu.Unlock()
 
-		// Simulate step processing time
-		time.Sleep(2 * time.Second)
+		// Indexes into step.Ingredients, so dispensed amounts end up in the plan
+		var pumped, manualIndexes []int
+		var items []plannedIngredient
+		var manual []models.ManualIngredientToAdd
+		for j, item := range step.Ingredients {
+			switch {
+			case item.Pump != nil:
+				pumped = append(pumped, j)
+				items = append(items, item)
+			case item.Ingredient != nil:
+				manualIndexes = append(manualIndexes, j)
+				manual = append(manual, manualIngredientToAdd(item))
+			}
+		}
+
+		if step.waitsForBartender() {
+			if err := s.waitForBartender(ctx, step.Message, manual); err != nil {
+				return
+			}
+			for _, j := range manualIndexes {
+				step.Ingredients[j].DispensedInMl = step.Ingredients[j].AmountInMl
+			}
+
+			dispensedMl += manualStepMl
+			s.mu.Lock()
+			s.currentOrder.PercentComplete = dispensedMl * 100 / totalMl
+			s.publishProgress()
+			s.mu.Unlock()
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		err := s.dispenseStep(ctx, step, pumped, items, dispensedMl, totalMl)
+		if err != nil {
+			var pumpErr *pumpStepError
+			if s.pumpRuntime.IsStopped() {
+				s.abortForEmergencyStop()
+			} else if errors.As(err, &pumpErr) && !errors.Is(err, context.Canceled) {
+				s.failOrder(fmt.Sprintf("Pump %s failed: %v", pumpDisplayName(pumpErr.Pump), pumpErr.Err))
+			} else {
+				s.failOrder(fmt.Sprintf("Production stopped: %v", err))
+			}
+			return
+		}
+
+		for _, item := range items {
+			dispensedMl += item.AmountInMl
+		}
 	}
 
 	// Mark as completed
@@ -162,25 +264,318 @@ func (s *CocktailService) produceCoktail(recipe *models.Recipe, config models.Co
 	s.currentOrder.Message = "Cocktail ready!"
 	s.currentOrder.PercentComplete = 100
 	s.currentOrder.CompletedAt = &now
+	s.publishProgress()
 	s.mu.Unlock()
 }
 
+// dispenseStep runs the pumps of a step and records the dispensed amounts in
+// the plan. Removing the glass stops the pumps, once a glass is back the
+// rest of the step is dispensed.
+func (s *CocktailService) dispenseStep(ctx context.Context, step plannedStep, pumped []int, items []plannedIngredient, dispensedMl int, totalMl int) error {
+	dispensed := make([]int, len(items))
+	defer func() {
+		for k, j := range pumped {
+			step.Ingredients[j].DispensedInMl = dispensed[k]
+		}
+	}()
+
+	// Indexes into items that still have to be dispensed
+	pending := make([]int, len(items))
+	for k := range items {
+		pending[k] = k
+	}
+
+	for len(pending) > 0 {
+		if err := s.waitForGlass(ctx); err != nil {
+			return err
+		}
+
+		run := make([]plannedIngredient, len(pending))
+		doneMl := dispensedMl
+		for n, k := range pending {
+			run[n] = items[k]
+			run[n].AmountInMl = items[k].AmountInMl - dispensed[k]
+			doneMl += dispensed[k]
+		}
+
+		stepCtx, interrupt := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.interruptStep = interrupt
+		if !s.dispensingArea.GlassReady() {
+			// Removed right after waitForGlass saw it
+			interrupt()
+		}
+		s.mu.Unlock()
+
+		var lastProgress []models.PumpProgress
+		executor := &stepExecutor{
+			run: s.pumpRuntime.Dispense,
+			onProgress: func(pumps []models.PumpProgress) {
+				lastProgress = pumps
+				s.updatePumpProgress(pumps, doneMl, totalMl)
+			},
+		}
+		err := executor.execute(stepCtx, run)
+		interrupted := ctx.Err() == nil && stepCtx.Err() != nil
+
+		s.mu.Lock()
+		s.interruptStep = nil
+		s.mu.Unlock()
+		interrupt()
+
+		for n, k := range pending {
+			if n < len(lastProgress) {
+				dispensed[k] += run[n].AmountInMl * lastProgress[n].PercentComplete / 100
+			}
+		}
+		if err == nil || !interrupted {
+			return err
+		}
+
+		var left []int
+		for _, k := range pending {
+			if dispensed[k] < items[k].AmountInMl {
+				left = append(left, k)
+			}
+		}
+		pending = left
+	}
+	return nil
+}
+
+// waitForGlass pauses production until an empty glass stands in the
+// dispensing area. If none is placed within the manual step timeout the
+// order is cancelled.
+func (s *CocktailService) waitForGlass(ctx context.Context) error {
+	s.mu.Lock()
+	if s.currentOrder.Status == "cancelled" {
+		s.mu.Unlock()
+		return context.Canceled
+	}
+	// Checked with s.mu held, so a glass placed right now still resumes
+	// the order through onDispensingAreaChange
+	if s.dispensingArea.GlassReady() {
+		s.mu.Unlock()
+		return nil
+	}
+	pausedUntil := time.Now().Add(s.manualStepTimeout)
+	s.currentOrder.Status = "paused"
+	s.currentOrder.Pumps = nil
+	s.currentOrder.PausedUntil = &pausedUntil
+	s.currentOrder.Message = "Please place an empty glass in the dispensing area"
+	resume := make(chan struct{}, 1)
+	s.resume = resume
+	s.waitingForGlass = true
+	s.publishProgress()
+	s.mu.Unlock()
+
+	return s.awaitResume(ctx, resume, "no glass was placed")
+}
+
+// onDispensingAreaChange pauses the running step when the glass is removed
+// and resumes production once a glass is ready
+func (s *CocktailService) onDispensingAreaChange() {
+	ready := s.dispensingArea.GlassReady()
+
+	s.mu.Lock()
+	if !ready && s.interruptStep != nil {
+		s.interruptStep()
+		s.interruptStep = nil
+	}
+	if ready && s.waitingForGlass && s.currentOrder != nil && s.currentOrder.Status == "paused" {
+		s.clearPause()
+		s.currentOrder.Status = "in_progress"
+		s.currentOrder.Message = "Production resumed"
+		if s.resume != nil {
+			s.resume <- struct{}{}
+			s.resume = nil
+		}
+		s.publishProgress()
+	}
+	s.mu.Unlock()
+
+	if ready {
+		go s.dispatchNext()
+	}
+}
+
+// waitForBartender pauses production until ContinueProduction is called.
+// If nobody continues within the manual step timeout the order is cancelled.
+func (s *CocktailService) waitForBartender(ctx context.Context, instruction string, manual []models.ManualIngredientToAdd) error {
+	s.mu.Lock()
+	if s.currentOrder.Status == "cancelled" {
+		s.mu.Unlock()
+		return context.Canceled
+	}
+	pausedUntil := time.Now().Add(s.manualStepTimeout)
+	s.currentOrder.Status = "paused"
+	s.currentOrder.Pumps = nil
+	s.currentOrder.WrittenInstruction = instruction
+	s.currentOrder.IngredientsToAddManually = manual
+	s.currentOrder.PausedUntil = &pausedUntil
+	if len(manual) > 0 {
+		s.currentOrder.Message = "Please add the ingredients manually and continue"
+	} else {
+		s.currentOrder.Message = "Please follow the instruction and continue"
+	}
+	resume := make(chan struct{}, 1)
+	s.resume = resume
+	s.publishProgress()
+	s.mu.Unlock()
+
+	return s.awaitResume(ctx, resume, "manual step was not confirmed")
+}
+
+// awaitResume waits until the paused order is resumed. If that does not
+// happen within the manual step timeout the order is cancelled.
+func (s *CocktailService) awaitResume(ctx context.Context, resume chan struct{}, reason string) error {
+	timer := time.NewTimer(s.manualStepTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-resume:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case <-timer.C:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.currentOrder.Status != "paused" {
+			// Continued or cancelled right as the timer fired
+			if s.currentOrder.Status == "in_progress" {
+				return nil
+			}
+			return context.Canceled
+		}
+
+		now := time.Now()
+		s.resume = nil
+		s.clearPause()
+		s.currentOrder.Status = "cancelled"
+		s.currentOrder.Message = fmt.Sprintf("Order cancelled: %s within %s", reason, s.manualStepTimeout)
+		s.currentOrder.CompletedAt = &now
+		s.publishProgress()
+		return errManualStepTimeout
+	}
+}
+
+// clearPause removes the pause details from the current order.
+// Must be called with s.mu held.
+func (s *CocktailService) clearPause() {
+	s.currentOrder.WrittenInstruction = ""
+	s.currentOrder.IngredientsToAddManually = nil
+	s.currentOrder.PausedUntil = nil
+	s.waitingForGlass = false
+}
+
+// manualIngredientToAdd describes a planned ingredient the bartender adds by hand
+func manualIngredientToAdd(item plannedIngredient) models.ManualIngredientToAdd {
+	unit := item.Ingredient.Unit
+	if unit == "" {
+		unit = "ml"
+	}
+	return models.ManualIngredientToAdd{
+		IngredientID:   item.Ingredient.ID,
+		IngredientName: item.Ingredient.Name,
+		Amount:         item.AmountInMl,
+		Unit:           unit,
+	}
+}
+
+// updatePumpProgress stores the per pump progress of the running step and
+// derives the overall progress from it
+func (s *CocktailService) updatePumpProgress(pumps []models.PumpProgress, dispensedMl int, totalMl int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentOrder.Status != "in_progress" {
+		return
+	}
+
+	stepMl := 0
+	for _, pump := range pumps {
+		stepMl += pump.AmountInMl * pump.PercentComplete / 100
+	}
+	if totalMl > 0 {
+		s.currentOrder.PercentComplete = (dispensedMl + stepMl) * 100 / totalMl
+	}
+	s.currentOrder.Pumps = pumps
+	s.publishProgress()
+}
+
+// abortForEmergencyStop cancels the current order after the pumps were
+// emergency stopped
+func (s *CocktailService) abortForEmergencyStop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentOrder == nil || (s.currentOrder.Status != "in_progress" && s.currentOrder.Status != "paused") {
+		return
+	}
+
+	now := time.Now()
+	s.clearPause()
+	s.currentOrder.Status = "cancelled"
+	s.currentOrder.Message = "Production stopped by emergency stop"
+	s.currentOrder.CompletedAt = &now
+	// The progress of the step still shows the pumps that were running
+	pumps := make([]models.PumpProgress, len(s.currentOrder.Pumps))
+	copy(pumps, s.currentOrder.Pumps)
+	for i := range pumps {
+		if pumps[i].Status == "running" {
+			pumps[i].Status = "stopped"
+		}
+	}
+	s.currentOrder.Pumps = pumps
+	if s.cancelCurrent != nil {
+		s.cancelCurrent()
+	}
+	s.publishProgress()
+}
+
+// failOrder moves the current order into the error state
+func (s *CocktailService) failOrder(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentOrder.Status == "cancelled" {
+		return
+	}
+
+	now := time.Now()
+	s.currentOrder.Status = "error"
+	s.currentOrder.Message = message
+	s.currentOrder.CompletedAt = &now
+	s.publishProgress()
+}
+
+// pumpDisplayName returns a human readable pump identifier
+func pumpDisplayName(pump *models.Pump) string {
+	if pump.Name != nil && *pump.Name != "" {
+		return *pump.Name
+	}
+	return fmt.Sprintf("#%d", pump.ID)
+}
+
 // GetCurrentProgress returns the current cocktail production progress
 func (s *CocktailService) GetCurrentProgress() *models.CocktailProgress {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	
+
 	if s.currentOrder == nil {
 		return nil
 	}
-	
+
 	// Return a copy to avoid race conditions
 	progress := *s.currentOrder
 	return &progress
 }
 
-// CancelOrder cancels the current cocktail order
-func (s *CocktailService) CancelOrder(userID int64) error {
+// CancelOrder cancels the cocktail that is currently being produced
+func (s *CocktailService) CancelOrder(userID int64, isAdmin bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -188,19 +583,23 @@ func (s *CocktailService) CancelOrder(userID int64) error {
 		return errors.New("no active order to cancel")
 	}
 
-	if s.currentOrder.Status != "in_progress" {
+	if s.currentOrder.Status != "in_progress" && s.currentOrder.Status != "paused" {
 		return errors.New("order is not in progress")
 	}
 
-	// Check if user owns this order (or is admin - handled in handler)
-	if s.currentOrder.UserID != userID {
+	if s.currentOrder.UserID != userID && !isAdmin {
 		return errors.New("you can only cancel your own orders")
 	}
 
+	s.clearPause()
 	s.currentOrder.Status = "cancelled"
 	s.currentOrder.Message = "Order cancelled by user"
 	now := time.Now()
 	s.currentOrder.CompletedAt = &now
+	if s.cancelCurrent != nil {
+		s.cancelCurrent()
+	}
+	s.publishProgress()
 
 	return nil
 }
@@ -218,8 +617,18 @@ func (s *CocktailService) ContinueProduction() error {
 		return errors.New("order is not paused")
 	}
 
+	if s.waitingForGlass {
+		return errors.New("place an empty glass in the dispensing area to continue")
+	}
+
+	s.clearPause()
 	s.currentOrder.Status = "in_progress"
 	s.currentOrder.Message = "Production resumed"
+	if s.resume != nil {
+		s.resume <- struct{}{}
+		s.resume = nil
+	}
+	s.publishProgress()
 
 	return nil
 }
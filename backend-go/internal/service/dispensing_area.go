@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
+)
+
+// glassSettleGrams is how much two readings in a row may differ for a glass
+// to count as standing still
+const glassSettleGrams = 5
+
+// maxFailedReads is how many load cell readings in a row may fail before
+// the glass no longer counts as ready. The HX711 misses a reading now and
+// then, which must not interrupt a running order.
+const maxFailedReads = 3
+
+// DispensingAreaService detects glasses in the dispensing area from the load
+// cell weight. While a load cell is configured it interlocks production:
+// orders only start on an empty glass that stands still, and removing the
+// glass pauses the running order.
+type DispensingAreaService struct {
+	loadCells    *LoadCellService
+	glassRepo    *repository.GlassRepository
+	publisher    EventPublisher
+	pollInterval time.Duration
+	glassWeight  float64
+	glassMatch   float64
+	enabled      bool // a load cell is configured
+	state        models.DispensingArea
+	settled      bool
+	lastWeight   *float64 // previous reading while the glass settles
+	failedReads  int      // failed readings in a row
+	onChange     []func()
+	mu           sync.RWMutex
+}
+
+// NewDispensingAreaService creates a new dispensing area service
+func NewDispensingAreaService(cfg *config.Config, loadCells *LoadCellService, glassRepo *repository.GlassRepository, publisher EventPublisher) *DispensingAreaService {
+	// Until the first reading the interlock holds whenever a load cell
+	// might be configured
+	loadCell, err := loadCells.Get()
+
+	return &DispensingAreaService{
+		loadCells:    loadCells,
+		glassRepo:    glassRepo,
+		publisher:    publisher,
+		pollInterval: cfg.LoadCell.PollInterval,
+		glassWeight:  float64(cfg.LoadCell.GlassWeight),
+		glassMatch:   float64(cfg.LoadCell.GlassMatch),
+		enabled:      err != nil || loadCell != nil,
+	}
+}
+
+// OnChange registers a function that is called when GlassReady may have
+// changed
+func (s *DispensingAreaService) OnChange(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onChange = append(s.onChange, fn)
+}
+
+// State returns the state of the dispensing area, nil if no load cell is
+// configured
+func (s *DispensingAreaService) State() *models.DispensingArea {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.enabled {
+		return nil
+	}
+	state := s.snapshot()
+	return &state
+}
+
+// GlassReady returns whether an order may be poured. Without a load cell
+// there is nothing to check.
+func (s *DispensingAreaService) GlassReady() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return !s.enabled || s.ready()
+}
+
+// DetectedGlass returns the known glass in the dispensing area, nil if
+// there is none or it was not recognised
+func (s *DispensingAreaService) DetectedGlass() *models.Glass {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.enabled || s.state.Glass == nil {
+		return nil
+	}
+	glass := *s.state.Glass
+	return &glass
+}
+
+// MarkFilled records that an order was poured into the glass in the
+// dispensing area. The next order waits until the glass is replaced.
+func (s *DispensingAreaService) MarkFilled() {
+	s.mu.Lock()
+	if !s.enabled || !s.state.GlassPresent {
+		s.mu.Unlock()
+		return
+	}
+	s.state.Filled = true
+	state := s.snapshot()
+	s.mu.Unlock()
+
+	s.publish(state)
+}
+
+// Run reads the load cell and publishes the dispensing area until ctx is
+// cancelled
+func (s *DispensingAreaService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.loadCells.Available() {
+			continue
+		}
+		reading, err := s.loadCells.Read(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Only log changes, a missing load cell would flood the log
+			if err.Error() != lastErr && !errors.Is(err, ErrLoadCellNotConfigured) {
+				log.Printf("Failed to read load cell: %v", err)
+			}
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+		s.update(reading, err)
+	}
+}
+
+// update applies a load cell reading, publishes the new state and notifies
+// the listeners if the glass became ready or not ready
+func (s *DispensingAreaService) update(reading *models.LoadCellReading, err error) {
+	s.mu.Lock()
+	wasEnabled := s.enabled
+	wasReady := !s.enabled || s.ready()
+
+	switch {
+	case errors.Is(err, ErrLoadCellNotConfigured):
+		s.enabled = false
+		s.state = models.DispensingArea{}
+		s.settled = false
+		s.lastWeight = nil
+		s.failedReads = 0
+	case err != nil:
+		// Keep what is known about the glass, a single failed reading
+		// must not turn a filled glass into an empty one
+		s.enabled = true
+		s.state.Error = err.Error()
+		s.failedReads++
+	default:
+		s.enabled = true
+		s.state.Error = ""
+		s.failedReads = 0
+		s.detect(reading)
+	}
+
+	enabled := s.enabled
+	changed := wasReady != (!s.enabled || s.ready())
+	state := s.snapshot()
+	listeners := s.onChange
+	s.mu.Unlock()
+
+	if enabled || wasEnabled {
+		s.publish(state)
+	}
+	if changed {
+		for _, fn := range listeners {
+			fn()
+		}
+	}
+}
+
+// detect tracks the glass from a weight reading. A glass counts once two
+// readings in a row agree, so a glass that is still being put down is
+// neither matched nor poured into. Must be called with s.mu held.
+func (s *DispensingAreaService) detect(reading *models.LoadCellReading) {
+	weight := reading.WeightInGrams
+	s.state.Reading = reading
+
+	if weight < s.glassWeight {
+		s.state.GlassPresent = false
+		s.state.Glass = nil
+		s.state.Filled = false
+		s.settled = false
+		s.lastWeight = nil
+		return
+	}
+
+	s.state.GlassPresent = true
+	if s.settled {
+		return
+	}
+	if s.lastWeight != nil && math.Abs(weight-*s.lastWeight) <= glassSettleGrams {
+		s.settled = true
+		s.state.Glass = s.matchGlass(weight)
+	}
+	s.lastWeight = &weight
+}
+
+// matchGlass returns the known glass whose empty weight is closest to the
+// weight, nil if none is within the match tolerance. Must be called with
+// s.mu held.
+func (s *DispensingAreaService) matchGlass(weight float64) *models.Glass {
+	glasses, err := s.glassRepo.FindAll()
+	if err != nil {
+		log.Printf("Failed to get glasses: %v", err)
+		return nil
+	}
+
+	var match *models.Glass
+	best := s.glassMatch
+	for i := range glasses {
+		if glasses[i].EmptyWeight == nil {
+			continue
+		}
+		diff := math.Abs(weight - float64(*glasses[i].EmptyWeight))
+		if diff <= best {
+			match = &glasses[i]
+			best = diff
+		}
+	}
+	return match
+}
+
+// ready returns whether an empty glass stands in the dispensing area.
+// Isolated failed readings keep the last known state. Must be called with
+// s.mu held.
+func (s *DispensingAreaService) ready() bool {
+	return s.failedReads < maxFailedReads && s.state.GlassPresent && s.settled && !s.state.Filled
+}
+
+// snapshot returns a copy of the state. Must be called with s.mu held.
+func (s *DispensingAreaService) snapshot() models.DispensingArea {
+	state := s.state
+	state.Ready = s.ready()
+	return state
+}
+
+// publish pushes the dispensing area to all subscribers
+func (s *DispensingAreaService) publish(state models.DispensingArea) {
+	if s.publisher == nil {
+		return
+	}
+	s.publisher.BroadcastDetectedGlass(state)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// newReadyDispensingArea returns a dispensing area with a settled empty
+// glass on its load cell
+func newReadyDispensingArea() *DispensingAreaService {
+	return &DispensingAreaService{
+		enabled: true,
+		state:   models.DispensingArea{GlassPresent: true},
+		settled: true,
+	}
+}
+
+func TestDispensingAreaReadErrors(t *testing.T) {
+	errRead := errors.New("HX711 is not responding")
+	tests := []struct {
+		name        string
+		errs        []error
+		wantEnabled bool
+		wantReady   bool
+	}{
+		{
+			name:        "load cell not configured",
+			errs:        []error{ErrLoadCellNotConfigured},
+			wantEnabled: false,
+			wantReady:   true,
+		},
+		{
+			name:        "wrapped load cell not configured",
+			errs:        []error{fmt.Errorf("failed to read: %w", ErrLoadCellNotConfigured)},
+			wantEnabled: false,
+			wantReady:   true,
+		},
+		{
+			name:        "isolated failed reading",
+			errs:        []error{errRead},
+			wantEnabled: true,
+			wantReady:   true,
+		},
+		{
+			name:        "too many failed readings",
+			errs:        []error{errRead, errRead, errRead},
+			wantEnabled: true,
+			wantReady:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			area := newReadyDispensingArea()
+			for _, err := range tt.errs {
+				area.update(nil, err)
+			}
+
+			if got := area.State() != nil; got != tt.wantEnabled {
+				t.Errorf("load cell enabled = %v, want %v", got, tt.wantEnabled)
+			}
+			if got := area.GlassReady(); got != tt.wantReady {
+				t.Errorf("GlassReady = %v, want %v", got, tt.wantReady)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package service
+
+// EventPublisher pushes state changes to connected clients.
+// It is implemented by websocket.Service.
+type EventPublisher interface {
+	BroadcastCocktailProgress(progress any)
+	BroadcastPumpLayout(pumps any)
+	BroadcastPumpRunningState(pumpID int64, state any)
+	BroadcastPumpCleaning(state any)
+	BroadcastPumpLowLevel(warning any)
+	BroadcastDetectedGlass(state any)
+	InvalidateRecipeScrollCaches()
+}
@@ -93,5 +93,9 @@ func (s *GlassService) validateGlass(glass *models.Glass) error {
 		return errors.New("glass size must be between 10 and 5000 ml")
 	}
 
+	if glass.EmptyWeight != nil && (*glass.EmptyWeight < 1 || *glass.EmptyWeight > 5000) {
+		return errors.New("glass empty weight must be between 1 and 5000 g")
+	}
+
 	return nil
 }
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
+)
+
+// Valid I2C addresses of GPIO expanders
+const (
+	minI2CAddress = 0x03
+	maxI2CAddress = 0x77
+)
+
+// GpioBoardService handles business logic for GPIO boards and their pins
+type GpioBoardService struct {
+	repo   *repository.GpioRepository
+	boards *GPIOBoards
+}
+
+// NewGpioBoardService creates a new GPIO board service
+func NewGpioBoardService(repo *repository.GpioRepository, boards *GPIOBoards) *GpioBoardService {
+	return &GpioBoardService{repo: repo, boards: boards}
+}
+
+// GetAllBoards returns all GPIO boards with the usage of their pins
+func (s *GpioBoardService) GetAllBoards() ([]models.GpioBoard, error) {
+	boards, err := s.repo.FindAllBoards()
+	if err != nil {
+		return nil, err
+	}
+	if boards == nil {
+		return []models.GpioBoard{}, nil
+	}
+
+	usages, err := s.repo.FindPinUsages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pin usages: %w", err)
+	}
+	for i := range boards {
+		attachPinUsages(&boards[i], usages)
+	}
+	return boards, nil
+}
+
+// GetBoard returns a GPIO board with the usage of its pins
+func (s *GpioBoardService) GetBoard(id int64) (*models.GpioBoard, error) {
+	board, err := s.repo.FindBoardByID(id)
+	if err != nil || board == nil {
+		return nil, err
+	}
+
+	usages, err := s.repo.FindPinUsages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pin usages: %w", err)
+	}
+	attachPinUsages(board, usages)
+	return board, nil
+}
+
+// CreateBoard creates a new GPIO board
+func (s *GpioBoardService) CreateBoard(board *models.GpioBoard) error {
+	board.ID = 0
+	board.Pins = nil
+	if err := s.validateBoard(board); err != nil {
+		return err
+	}
+
+	return s.repo.CreateBoard(board)
+}
+
+// UpdateBoard updates an existing GPIO board
+func (s *GpioBoardService) UpdateBoard(board *models.GpioBoard) error {
+	existing, err := s.repo.FindBoardByID(board.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find board: %w", err)
+	}
+	if existing == nil {
+		return errors.New("board not found")
+	}
+
+	if err := s.validateBoard(board); err != nil {
+		return err
+	}
+	for _, pin := range existing.Pins {
+		if pin.PinNr >= board.NumberOfPins() {
+			return fmt.Errorf("pin %d does not exist on a %s board, remove it first", pin.PinNr, boardKind(board))
+		}
+	}
+
+	if err := s.repo.UpdateBoard(board); err != nil {
+		return err
+	}
+
+	s.boards.Invalidate(board.ID)
+	return nil
+}
+
+// DeleteBoard deletes a GPIO board and its pins. Boards with pins in use
+// cannot be deleted.
+func (s *GpioBoardService) DeleteBoard(id int64) error {
+	board, err := s.repo.FindBoardByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to find board: %w", err)
+	}
+	if board == nil {
+		return errors.New("board not found")
+	}
+
+	usages, err := s.repo.FindPinUsages()
+	if err != nil {
+		return fmt.Errorf("failed to get pin usages: %w", err)
+	}
+	for _, usage := range usages {
+		if usage.Board == id {
+			return fmt.Errorf("pin %d of board %s is used by %s", usage.PinNr, board.Name, describePinUsage(usage))
+		}
+	}
+
+	if err := s.repo.DeleteBoard(id); err != nil {
+		return err
+	}
+
+	s.boards.Invalidate(id)
+	return nil
+}
+
+// GetPins returns the pins of a GPIO board with their usage
+func (s *GpioBoardService) GetPins(boardID int64) ([]models.GpioPin, error) {
+	board, err := s.GetBoard(boardID)
+	if err != nil {
+		return nil, err
+	}
+	if board == nil {
+		return nil, errors.New("board not found")
+	}
+	if board.Pins == nil {
+		return []models.GpioPin{}, nil
+	}
+	return board.Pins, nil
+}
+
+// CreatePin adds a pin to a GPIO board
+func (s *GpioBoardService) CreatePin(boardID int64, pinNr int) (*models.GpioPin, error) {
+	board, err := s.repo.FindBoardByID(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find board: %w", err)
+	}
+	if board == nil {
+		return nil, errors.New("board not found")
+	}
+	if err := checkPinNr(board, pinNr); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindPin(boardID, pinNr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pin: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("pin %d already exists on board %s", pinNr, board.Name)
+	}
+
+	pin := &models.GpioPin{Board: boardID, PinNr: pinNr, UsedBy: []models.PinUsage{}}
+	if err := s.repo.CreatePin(pin); err != nil {
+		return nil, err
+	}
+	return pin, nil
+}
+
+// DeletePin removes a pin from a GPIO board. Pins in use cannot be deleted.
+func (s *GpioBoardService) DeletePin(boardID int64, pinNr int) error {
+	pin, err := s.repo.FindPin(boardID, pinNr)
+	if err != nil {
+		return fmt.Errorf("failed to find pin: %w", err)
+	}
+	if pin == nil {
+		return errors.New("pin not found")
+	}
+
+	usages, err := s.repo.FindPinUsages()
+	if err != nil {
+		return fmt.Errorf("failed to get pin usages: %w", err)
+	}
+	for _, usage := range usages {
+		if usage.Board == boardID && usage.PinNr == pinNr {
+			return fmt.Errorf("pin %d is used by %s", pinNr, describePinUsage(usage))
+		}
+	}
+
+	return s.repo.DeletePin(boardID, pinNr)
+}
+
+// validateBoard validates board data
+func (s *GpioBoardService) validateBoard(board *models.GpioBoard) error {
+	board.Name = strings.TrimSpace(board.Name)
+	if board.Name == "" {
+		return errors.New("board name is required")
+	}
+
+	switch board.DType {
+	case models.GpioBoardLocal:
+		board.BoardModel = nil
+		board.I2CAddress = nil
+	case models.GpioBoardI2C:
+		if board.BoardModel == nil || (*board.BoardModel != models.BoardModelMCP23017 && *board.BoardModel != models.BoardModelPCF8574) {
+			return fmt.Errorf("I2C board requires boardModel %s or %s", models.BoardModelMCP23017, models.BoardModelPCF8574)
+		}
+		if board.I2CAddress == nil || *board.I2CAddress < minI2CAddress || *board.I2CAddress > maxI2CAddress {
+			return fmt.Errorf("I2C board requires i2cAddress between 0x%02x and 0x%02x", minI2CAddress, maxI2CAddress)
+		}
+	default:
+		return fmt.Errorf("invalid board type: %s", board.DType)
+	}
+
+	existing, err := s.repo.FindBoardByName(board.Name)
+	if err != nil {
+		return fmt.Errorf("failed to validate board name: %w", err)
+	}
+	if existing != nil && existing.ID != board.ID {
+		return errors.New("board with this name already exists")
+	}
+
+	boards, err := s.repo.FindAllBoards()
+	if err != nil {
+		return fmt.Errorf("failed to get boards: %w", err)
+	}
+	for _, other := range boards {
+		if other.ID == board.ID || other.DType != board.DType {
+			continue
+		}
+		if board.DType == models.GpioBoardLocal {
+			return errors.New("a local board already exists")
+		}
+		if *other.I2CAddress == *board.I2CAddress {
+			return fmt.Errorf("I2C address 0x%02x is already used by board %s", *board.I2CAddress, other.Name)
+		}
+	}
+
+	return nil
+}
+
+// validatePinAssignments checks that every pin in assignments exists on its
+// board and is not assigned to another function, neither within
+// assignments nor elsewhere. Pins that are not registered yet are created
+// when the owner is saved, see assignedPins. All assignments must belong to
+// the same owner.
+func validatePinAssignments(repo *repository.GpioRepository, assignments []models.PinUsage) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	for i, assignment := range assignments {
+		for _, other := range assignments[:i] {
+			if other.Board == assignment.Board && other.PinNr == assignment.PinNr {
+				return fmt.Errorf("pin %d cannot be used as %s and %s at the same time", assignment.PinNr, other.Function, assignment.Function)
+			}
+		}
+	}
+
+	usages, err := repo.FindPinUsages()
+	if err != nil {
+		return fmt.Errorf("failed to get pin usages: %w", err)
+	}
+	owner := assignments[0]
+	for _, usage := range usages {
+		if usage.Type == owner.Type && usage.ID == owner.ID {
+			continue
+		}
+		for _, assignment := range assignments {
+			if usage.Board == assignment.Board && usage.PinNr == assignment.PinNr {
+				return fmt.Errorf("pin %d is already used by %s", assignment.PinNr, describePinUsage(usage))
+			}
+		}
+	}
+
+	for _, assignment := range assignments {
+		board, err := repo.FindBoardByID(assignment.Board)
+		if err != nil {
+			return fmt.Errorf("failed to find board: %w", err)
+		}
+		if board == nil {
+			return fmt.Errorf("board %d not found", assignment.Board)
+		}
+		if err := checkPinNr(board, assignment.PinNr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assignedPins returns the pins of assignments, which are registered on
+// their boards together with the owner
+func assignedPins(assignments []models.PinUsage) []models.GpioPin {
+	pins := make([]models.GpioPin, 0, len(assignments))
+	for _, assignment := range assignments {
+		pins = append(pins, models.GpioPin{Board: assignment.Board, PinNr: assignment.PinNr})
+	}
+	return pins
+}
+
+// attachPinUsages sets the pin count of a board and the functions each of
+// its pins is assigned to
+func attachPinUsages(board *models.GpioBoard, usages []models.PinUsage) {
+	board.PinCount = board.NumberOfPins()
+	for i := range board.Pins {
+		pin := &board.Pins[i]
+		pin.UsedBy = []models.PinUsage{}
+		for _, usage := range usages {
+			if usage.Board == pin.Board && usage.PinNr == pin.PinNr {
+				pin.UsedBy = append(pin.UsedBy, usage)
+			}
+		}
+	}
+}
+
+// checkPinNr checks that a board provides a pin
+func checkPinNr(board *models.GpioBoard, pinNr int) error {
+	if pinNr < 0 || pinNr >= board.NumberOfPins() {
+		return fmt.Errorf("pin %d does not exist on board %s (valid pins are 0-%d)", pinNr, board.Name, board.NumberOfPins()-1)
+	}
+	return nil
+}
+
+// boardKind returns a readable description of the board type
+func boardKind(board *models.GpioBoard) string {
+	if board.DType == models.GpioBoardI2C && board.BoardModel != nil {
+		return *board.BoardModel
+	}
+	return board.DType
+}
+
+// describePinUsage returns a readable description of a pin usage
+func describePinUsage(usage models.PinUsage) string {
+	switch usage.Type {
+	case "pump":
+		return fmt.Sprintf("pump %s (%s)", usage.Name, usage.Function)
+	case "eventAction":
+		return fmt.Sprintf("event action %s", usage.Name)
+	default:
+		return fmt.Sprintf("%s (%s)", usage.Name, usage.Function)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
+)
+
+// errGPIONotAvailable is returned if the local GPIO chip could not be opened
+var errGPIONotAvailable = errors.New("GPIO service not available")
+
+// expanderService is the GPIO service of an I2C expander board together
+// with the configuration it was created for
+type expanderService struct {
+	service *GPIOService
+	model   string
+	addr    int
+}
+
+// GPIOBoards routes pin access to the driver of the board a pin belongs
+// to. Pins without a board or on the local board use the local GPIO chip,
+// pins on I2C boards use an expander driver on the configured I2C bus.
+// Boards are cached, so the board service invalidates a board when it
+// changes.
+type GPIOBoards struct {
+	local       *GPIOService
+	repo        *repository.GpioRepository
+	i2cSettings func() models.I2CSettings
+	openBus     func(busID int) (I2CBus, error)
+	bus         I2CBus
+	busID       int
+	boards      map[int64]*models.GpioBoard
+	expanders   map[int64]*expanderService
+	mu          sync.Mutex
+}
+
+// NewGPIOBoards creates a new board router.
+// local may be nil when the local GPIO chip is not available.
+func NewGPIOBoards(local *GPIOService, repo *repository.GpioRepository, i2cSettings func() models.I2CSettings, openBus func(busID int) (I2CBus, error)) *GPIOBoards {
+	return &GPIOBoards{
+		local:       local,
+		repo:        repo,
+		i2cSettings: i2cSettings,
+		openBus:     openBus,
+		boards:      make(map[int64]*models.GpioBoard),
+		expanders:   make(map[int64]*expanderService),
+	}
+}
+
+// Available reports whether any board can be driven
+func (b *GPIOBoards) Available() bool {
+	return b.local != nil || b.i2cSettings().Enabled
+}
+
+// Local returns the GPIO service of the local GPIO chip, nil if it is not
+// available
+func (b *GPIOBoards) Local() *GPIOService {
+	return b.local
+}
+
+// Service returns the GPIO service driving the pins of a board. A nil
+// board ID refers to the local GPIO chip.
+func (b *GPIOBoards) Service(boardID *int64) (*GPIOService, error) {
+	if boardID == nil {
+		return b.localService()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	board, err := b.board(*boardID)
+	if err != nil {
+		return nil, err
+	}
+	if board.DType != models.GpioBoardI2C {
+		return b.localService()
+	}
+	if board.BoardModel == nil || board.I2CAddress == nil {
+		return nil, fmt.Errorf("board %s is not fully configured", board.Name)
+	}
+
+	settings := b.i2cSettings()
+	if !settings.Enabled {
+		return nil, fmt.Errorf("I2C is disabled, enable it to use board %s", board.Name)
+	}
+
+	if b.bus == nil || b.busID != settings.BusID {
+		b.closeI2C()
+		bus, err := b.openBus(settings.BusID)
+		if err != nil {
+			return nil, err
+		}
+		b.bus = bus
+		b.busID = settings.BusID
+	}
+
+	if cached, exists := b.expanders[board.ID]; exists {
+		if cached.model == *board.BoardModel && cached.addr == *board.I2CAddress {
+			return cached.service, nil
+		}
+		cached.service.Close()
+		delete(b.expanders, board.ID)
+	}
+
+	driver, err := NewExpanderDriver(b.bus, *board.BoardModel, uint16(*board.I2CAddress))
+	if err != nil {
+		return nil, err
+	}
+	gpio := NewGPIOService(driver)
+	b.expanders[board.ID] = &expanderService{
+		service: gpio,
+		model:   *board.BoardModel,
+		addr:    *board.I2CAddress,
+	}
+	return gpio, nil
+}
+
+// Invalidate drops a changed or deleted board from the cache. The expander
+// driver of the board is replaced on its next use if the board model or
+// address changed.
+func (b *GPIOBoards) Invalidate(boardID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.boards, boardID)
+}
+
+// Close releases the pins of all boards and closes the I2C bus
+func (b *GPIOBoards) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.closeI2C()
+	if b.local != nil {
+		if localErr := b.local.Close(); localErr != nil {
+			err = localErr
+		}
+	}
+	return err
+}
+
+// localService returns the GPIO service of the local GPIO chip
+func (b *GPIOBoards) localService() (*GPIOService, error) {
+	if b.local == nil {
+		return nil, errGPIONotAvailable
+	}
+	return b.local, nil
+}
+
+// board returns a board from the cache, loading it on first use.
+// Must be called with b.mu held.
+func (b *GPIOBoards) board(id int64) (*models.GpioBoard, error) {
+	if board, exists := b.boards[id]; exists {
+		return board, nil
+	}
+
+	board, err := b.repo.FindBoardByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find board: %w", err)
+	}
+	if board == nil {
+		return nil, fmt.Errorf("board %d not found", id)
+	}
+	b.boards[id] = board
+	return board, nil
+}
+
+// closeI2C closes all expander drivers and the I2C bus.
+// Must be called with b.mu held.
+func (b *GPIOBoards) closeI2C() error {
+	var lastErr error
+	for id, expander := range b.expanders {
+		if err := expander.service.Close(); err != nil {
+			lastErr = err
+		}
+		delete(b.expanders, id)
+	}
+	if b.bus != nil {
+		if err := b.bus.Close(); err != nil {
+			lastErr = err
+		}
+		b.bus = nil
+	}
+	return lastErr
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
+	"github.com/warthog618/go-gpiocdev"
+)
+
+// PinDriver is the low level access to GPIO pins used by GPIOService
+type PinDriver interface {
+	// Name identifies the driver, for example in the GPIO status
+	Name() string
+	// SetupOutput configures a pin as output, starting at the level value.
+	// A pin that already is an output keeps its level.
+	SetupOutput(pin int, value int) error
+	// SetupInput configures a pin as input
+	SetupInput(pin int) error
+	// SetValue drives an output pin to 0 or 1
+	SetValue(pin int, value int) error
+	// Value reads the current level of a pin
+	Value(pin int) (int, error)
+	// Release frees a pin
+	Release(pin int) error
+	// Close frees all pins and the driver itself
+	Close() error
+}
+
+// NewPinDriver creates the pin driver selected in the configuration
+func NewPinDriver(cfg config.GPIOConfig) (PinDriver, error) {
+	switch cfg.Driver {
+	case "gpiocdev":
+		return newGPIOCdevDriver(cfg.Chip)
+	case "simulated":
+		return NewSimulatedPinDriver(), nil
+	default:
+		return nil, fmt.Errorf("unknown GPIO driver: %s", cfg.Driver)
+	}
+}
+
+// gpiocdevDriver drives pins through the Linux GPIO character device
+type gpiocdevDriver struct {
+	chip  *gpiocdev.Chip
+	lines map[int]*gpiocdev.Line
+	mu    sync.Mutex
+}
+
+// newGPIOCdevDriver opens a GPIO chip, gpiochip0 on a Raspberry Pi
+func newGPIOCdevDriver(chipName string) (*gpiocdevDriver, error) {
+	chip, err := gpiocdev.NewChip(chipName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GPIO chip: %w", err)
+	}
+
+	return &gpiocdevDriver{
+		chip:  chip,
+		lines: make(map[int]*gpiocdev.Line),
+	}, nil
+}
+
+func (d *gpiocdevDriver) Name() string {
+	return d.chip.Name
+}
+
+func (d *gpiocdevDriver) SetupOutput(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.lines[pin]; exists {
+		return nil // Already configured
+	}
+
+	line, err := d.chip.RequestLine(pin, gpiocdev.AsOutput(value))
+	if err != nil {
+		return fmt.Errorf("failed to request pin %d as output: %w", pin, err)
+	}
+
+	d.lines[pin] = line
+	return nil
+}
+
+func (d *gpiocdevDriver) SetupInput(pin int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if line, exists := d.lines[pin]; exists {
+		if err := line.Reconfigure(gpiocdev.AsInput); err != nil {
+			return fmt.Errorf("failed to reconfigure pin %d as input: %w", pin, err)
+		}
+		return nil
+	}
+
+	line, err := d.chip.RequestLine(pin, gpiocdev.AsInput)
+	if err != nil {
+		return fmt.Errorf("failed to request pin %d as input: %w", pin, err)
+	}
+
+	d.lines[pin] = line
+	return nil
+}
+
+func (d *gpiocdevDriver) SetValue(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	line, exists := d.lines[pin]
+	if !exists {
+		return fmt.Errorf("pin %d not configured", pin)
+	}
+
+	return line.SetValue(value)
+}
+
+func (d *gpiocdevDriver) Value(pin int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	line, exists := d.lines[pin]
+	if !exists {
+		return 0, fmt.Errorf("pin %d not configured", pin)
+	}
+
+	return line.Value()
+}
+
+func (d *gpiocdevDriver) Release(pin int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	line, exists := d.lines[pin]
+	if !exists {
+		return nil // Already released
+	}
+
+	if err := line.Close(); err != nil {
+		return err
+	}
+
+	delete(d.lines, pin)
+	return nil
+}
+
+func (d *gpiocdevDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for pin, line := range d.lines {
+		if err := line.Close(); err != nil {
+			return fmt.Errorf("failed to close pin %d: %w", pin, err)
+		}
+		delete(d.lines, pin)
+	}
+
+	if err := d.chip.Close(); err != nil {
+		return fmt.Errorf("failed to close GPIO chip: %w", err)
+	}
+
+	return nil
+}
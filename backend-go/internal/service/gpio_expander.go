@@ -0,0 +1,332 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// MCP23017 registers with IOCON.BANK = 0 (power-on default). Register A
+// holds pins 0-7, register B pins 8-15.
+const (
+	mcp23017IODIRA = 0x00
+	mcp23017GPIOA  = 0x12
+	mcp23017OLATA  = 0x14
+)
+
+// NewExpanderDriver creates the pin driver of an I2C GPIO expander
+func NewExpanderDriver(bus I2CBus, boardModel string, addr uint16) (PinDriver, error) {
+	switch boardModel {
+	case models.BoardModelMCP23017:
+		return newMCP23017Driver(bus, addr), nil
+	case models.BoardModelPCF8574:
+		return newPCF8574Driver(bus, addr), nil
+	default:
+		return nil, fmt.Errorf("unsupported board model: %s", boardModel)
+	}
+}
+
+// mcp23017Driver drives the 16 pins of an MCP23017. Direction and output
+// latch are cached so a pin change needs a single register write.
+type mcp23017Driver struct {
+	bus   I2CBus
+	addr  uint16
+	iodir [2]byte
+	olat  [2]byte
+	mu    sync.Mutex
+}
+
+func newMCP23017Driver(bus I2CBus, addr uint16) *mcp23017Driver {
+	// All pins are inputs after power-on
+	return &mcp23017Driver{
+		bus:   bus,
+		addr:  addr,
+		iodir: [2]byte{0xFF, 0xFF},
+	}
+}
+
+func (d *mcp23017Driver) Name() string {
+	return fmt.Sprintf("%s@0x%02x", models.BoardModelMCP23017, d.addr)
+}
+
+func (d *mcp23017Driver) SetupOutput(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	port, bit, err := d.locate(pin)
+	if err != nil {
+		return err
+	}
+	if d.iodir[port]&bit == 0 {
+		return nil // Already configured
+	}
+
+	// Latch the initial level before switching the pin to output
+	olat := d.olat[port] &^ bit
+	if value != 0 {
+		olat |= bit
+	}
+	if err := d.writeRegister(mcp23017OLATA+port, olat); err != nil {
+		return err
+	}
+	d.olat[port] = olat
+	if err := d.writeRegister(mcp23017IODIRA+port, d.iodir[port]&^bit); err != nil {
+		return err
+	}
+	d.iodir[port] &^= bit
+	return nil
+}
+
+func (d *mcp23017Driver) SetupInput(pin int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	port, bit, err := d.locate(pin)
+	if err != nil {
+		return err
+	}
+	if d.iodir[port]&bit != 0 {
+		return nil // Already configured
+	}
+
+	if err := d.writeRegister(mcp23017IODIRA+port, d.iodir[port]|bit); err != nil {
+		return err
+	}
+	d.iodir[port] |= bit
+	return nil
+}
+
+func (d *mcp23017Driver) SetValue(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	port, bit, err := d.locate(pin)
+	if err != nil {
+		return err
+	}
+	if d.iodir[port]&bit != 0 {
+		return fmt.Errorf("pin %d not configured", pin)
+	}
+
+	olat := d.olat[port] &^ bit
+	if value != 0 {
+		olat |= bit
+	}
+	if olat == d.olat[port] {
+		return nil
+	}
+	if err := d.writeRegister(mcp23017OLATA+port, olat); err != nil {
+		return err
+	}
+	d.olat[port] = olat
+	return nil
+}
+
+func (d *mcp23017Driver) Value(pin int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	port, bit, err := d.locate(pin)
+	if err != nil {
+		return 0, err
+	}
+
+	value := d.olat[port]
+	if d.iodir[port]&bit != 0 {
+		buf := make([]byte, 1)
+		if err := d.bus.Tx(d.addr, []byte{mcp23017GPIOA + port}, buf); err != nil {
+			return 0, err
+		}
+		value = buf[0]
+	}
+	if value&bit != 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Release switches the pin back to input, its power-on state
+func (d *mcp23017Driver) Release(pin int) error {
+	return d.SetupInput(pin)
+}
+
+func (d *mcp23017Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var lastErr error
+	for port := byte(0); port < 2; port++ {
+		if d.iodir[port] == 0xFF {
+			continue
+		}
+		if err := d.writeRegister(mcp23017IODIRA+port, 0xFF); err != nil {
+			lastErr = err
+			continue
+		}
+		d.iodir[port] = 0xFF
+	}
+	return lastErr
+}
+
+// locate returns the port (0 = A, 1 = B) and bit mask of a pin
+func (d *mcp23017Driver) locate(pin int) (byte, byte, error) {
+	if pin < 0 || pin > 15 {
+		return 0, 0, fmt.Errorf("pin %d does not exist on %s", pin, d.Name())
+	}
+	return byte(pin / 8), 1 << (pin % 8), nil
+}
+
+func (d *mcp23017Driver) writeRegister(reg byte, value byte) error {
+	if err := d.bus.Tx(d.addr, []byte{reg, value}, nil); err != nil {
+		return fmt.Errorf("failed to write %s register 0x%02x: %w", d.Name(), reg, err)
+	}
+	return nil
+}
+
+// pcf8574Driver drives the 8 quasi-bidirectional pins of a PCF8574. The
+// chip has no registers: writing a byte sets all pins at once, a high bit
+// leaves the pin weakly pulled up so it can be used as input.
+type pcf8574Driver struct {
+	bus     I2CBus
+	addr    uint16
+	state   byte
+	outputs byte
+	mu      sync.Mutex
+}
+
+func newPCF8574Driver(bus I2CBus, addr uint16) *pcf8574Driver {
+	return &pcf8574Driver{
+		bus:   bus,
+		addr:  addr,
+		state: 0xFF,
+	}
+}
+
+func (d *pcf8574Driver) Name() string {
+	return fmt.Sprintf("%s@0x%02x", models.BoardModelPCF8574, d.addr)
+}
+
+func (d *pcf8574Driver) SetupOutput(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	bit, err := d.locate(pin)
+	if err != nil {
+		return err
+	}
+	if d.outputs&bit != 0 {
+		return nil // Already configured
+	}
+
+	state := d.state &^ bit
+	if value != 0 {
+		state |= bit
+	}
+	if err := d.write(state); err != nil {
+		return err
+	}
+	d.outputs |= bit
+	return nil
+}
+
+// SetupInput leaves the pin weakly pulled up so an external signal can
+// pull it low
+func (d *pcf8574Driver) SetupInput(pin int) error {
+	return d.Release(pin)
+}
+
+func (d *pcf8574Driver) SetValue(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	bit, err := d.locate(pin)
+	if err != nil {
+		return err
+	}
+	if d.outputs&bit == 0 {
+		return fmt.Errorf("pin %d not configured", pin)
+	}
+
+	state := d.state &^ bit
+	if value != 0 {
+		state |= bit
+	}
+	if state == d.state {
+		return nil
+	}
+	return d.write(state)
+}
+
+func (d *pcf8574Driver) Value(pin int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	bit, err := d.locate(pin)
+	if err != nil {
+		return 0, err
+	}
+
+	value := d.state
+	if d.outputs&bit == 0 {
+		buf := make([]byte, 1)
+		if err := d.bus.Tx(d.addr, nil, buf); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", d.Name(), err)
+		}
+		value = buf[0]
+	}
+	if value&bit != 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (d *pcf8574Driver) Release(pin int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	bit, err := d.locate(pin)
+	if err != nil {
+		return err
+	}
+	if d.outputs&bit == 0 {
+		return nil
+	}
+
+	if err := d.write(d.state | bit); err != nil {
+		return err
+	}
+	d.outputs &^= bit
+	return nil
+}
+
+func (d *pcf8574Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.outputs == 0 {
+		return nil
+	}
+	if err := d.write(0xFF); err != nil {
+		return err
+	}
+	d.outputs = 0
+	return nil
+}
+
+// locate returns the bit mask of a pin
+func (d *pcf8574Driver) locate(pin int) (byte, error) {
+	if pin < 0 || pin > 7 {
+		return 0, fmt.Errorf("pin %d does not exist on %s", pin, d.Name())
+	}
+	return 1 << pin, nil
+}
+
+// write sets all pins of the chip
+func (d *pcf8574Driver) write(state byte) error {
+	if err := d.bus.Tx(d.addr, []byte{state}, nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", d.Name(), err)
+	}
+	d.state = state
+	return nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+const testExpanderAddr = 0x20
+
+func TestMCP23017Driver(t *testing.T) {
+	tests := []struct {
+		pin       int
+		value     int
+		wantOLAT  []byte // register and latch written before the pin becomes an output
+		wantIODIR []byte // register and direction that make the pin an output
+		wantSet   []byte // latch written when the pin is switched to the other level
+	}{
+		{pin: 0, value: 0, wantOLAT: []byte{0x14, 0x00}, wantIODIR: []byte{0x00, 0xFE}, wantSet: []byte{0x14, 0x01}},
+		{pin: 0, value: 1, wantOLAT: []byte{0x14, 0x01}, wantIODIR: []byte{0x00, 0xFE}, wantSet: []byte{0x14, 0x00}},
+		{pin: 3, value: 1, wantOLAT: []byte{0x14, 0x08}, wantIODIR: []byte{0x00, 0xF7}, wantSet: []byte{0x14, 0x00}},
+		{pin: 7, value: 0, wantOLAT: []byte{0x14, 0x00}, wantIODIR: []byte{0x00, 0x7F}, wantSet: []byte{0x14, 0x80}},
+		{pin: 8, value: 0, wantOLAT: []byte{0x15, 0x00}, wantIODIR: []byte{0x01, 0xFE}, wantSet: []byte{0x15, 0x01}},
+		{pin: 8, value: 1, wantOLAT: []byte{0x15, 0x01}, wantIODIR: []byte{0x01, 0xFE}, wantSet: []byte{0x15, 0x00}},
+		{pin: 12, value: 0, wantOLAT: []byte{0x15, 0x00}, wantIODIR: []byte{0x01, 0xEF}, wantSet: []byte{0x15, 0x10}},
+		{pin: 15, value: 1, wantOLAT: []byte{0x15, 0x80}, wantIODIR: []byte{0x01, 0x7F}, wantSet: []byte{0x15, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("pin %d value %d", tt.pin, tt.value), func(t *testing.T) {
+			bus := NewFakeI2CBus()
+			bus.AddRegisterDevice(testExpanderAddr)
+			driver := newMCP23017Driver(bus, testExpanderAddr)
+
+			if err := driver.SetupOutput(tt.pin, tt.value); err != nil {
+				t.Fatalf("SetupOutput: %v", err)
+			}
+			assertWrites(t, bus, tt.wantOLAT, tt.wantIODIR)
+
+			if err := driver.SetValue(tt.pin, 1-tt.value); err != nil {
+				t.Fatalf("SetValue: %v", err)
+			}
+			assertWrites(t, bus, tt.wantOLAT, tt.wantIODIR, tt.wantSet)
+
+			// Setting the same level again must not touch the bus
+			if err := driver.SetValue(tt.pin, 1-tt.value); err != nil {
+				t.Fatalf("SetValue: %v", err)
+			}
+			assertWrites(t, bus, tt.wantOLAT, tt.wantIODIR, tt.wantSet)
+
+			if err := driver.Release(tt.pin); err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+			assertWrites(t, bus, tt.wantOLAT, tt.wantIODIR, tt.wantSet, []byte{tt.wantIODIR[0], 0xFF})
+		})
+	}
+}
+
+func TestMCP23017DriverAllPins(t *testing.T) {
+	for pin := 0; pin < 16; pin++ {
+		for _, value := range []int{0, 1} {
+			bus := NewFakeI2CBus()
+			bus.AddRegisterDevice(testExpanderAddr)
+			driver := newMCP23017Driver(bus, testExpanderAddr)
+
+			if err := driver.SetupOutput(pin, value); err != nil {
+				t.Fatalf("pin %d: SetupOutput: %v", pin, err)
+			}
+
+			port, bit := byte(pin/8), byte(1)<<(pin%8)
+			if got, want := bus.Register(testExpanderAddr, 0x00+port), ^bit; got != want {
+				t.Errorf("pin %d value %d: IODIR = %08b, want %08b", pin, value, got, want)
+			}
+			if got, want := bus.Register(testExpanderAddr, 0x01-port), byte(0); got != want {
+				t.Errorf("pin %d value %d: IODIR of the other port = %08b, want untouched", pin, value, got)
+			}
+			wantOLAT := byte(0)
+			if value == 1 {
+				wantOLAT = bit
+			}
+			if got := bus.Register(testExpanderAddr, 0x14+port); got != wantOLAT {
+				t.Errorf("pin %d value %d: OLAT = %08b, want %08b", pin, value, got, wantOLAT)
+			}
+			if got, err := driver.Value(pin); err != nil || got != value {
+				t.Errorf("pin %d: Value = %d, %v, want %d", pin, got, err, value)
+			}
+		}
+	}
+
+	driver := newMCP23017Driver(NewFakeI2CBus(), testExpanderAddr)
+	if err := driver.SetupOutput(16, 0); err == nil {
+		t.Error("SetupOutput(16) succeeded, want an error")
+	}
+}
+
+func TestPCF8574Driver(t *testing.T) {
+	tests := []struct {
+		pin       int
+		value     int
+		wantSetup byte // port written when the pin becomes an output
+		wantSet   byte // port written when the pin is switched to the other level
+	}{
+		{pin: 0, value: 0, wantSetup: 0xFE, wantSet: 0xFF},
+		{pin: 0, value: 1, wantSetup: 0xFF, wantSet: 0xFE},
+		{pin: 2, value: 0, wantSetup: 0xFB, wantSet: 0xFF},
+		{pin: 5, value: 1, wantSetup: 0xFF, wantSet: 0xDF},
+		{pin: 7, value: 0, wantSetup: 0x7F, wantSet: 0xFF},
+		{pin: 7, value: 1, wantSetup: 0xFF, wantSet: 0x7F},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("pin %d value %d", tt.pin, tt.value), func(t *testing.T) {
+			bus := NewFakeI2CBus()
+			bus.AddPortDevice(testExpanderAddr)
+			driver := newPCF8574Driver(bus, testExpanderAddr)
+
+			if err := driver.SetupOutput(tt.pin, tt.value); err != nil {
+				t.Fatalf("SetupOutput: %v", err)
+			}
+			assertWrites(t, bus, []byte{tt.wantSetup})
+
+			if err := driver.SetValue(tt.pin, 1-tt.value); err != nil {
+				t.Fatalf("SetValue: %v", err)
+			}
+			assertWrites(t, bus, []byte{tt.wantSetup}, []byte{tt.wantSet})
+
+			// Releasing writes the pin high, so it is weakly pulled up again
+			if err := driver.Release(tt.pin); err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+			assertWrites(t, bus, []byte{tt.wantSetup}, []byte{tt.wantSet}, []byte{0xFF})
+		})
+	}
+}
+
+func TestPCF8574DriverAllPins(t *testing.T) {
+	for pin := 0; pin < 8; pin++ {
+		for _, value := range []int{0, 1} {
+			bus := NewFakeI2CBus()
+			bus.AddPortDevice(testExpanderAddr)
+			driver := newPCF8574Driver(bus, testExpanderAddr)
+
+			if err := driver.SetupOutput(pin, value); err != nil {
+				t.Fatalf("pin %d: SetupOutput: %v", pin, err)
+			}
+
+			// Every other pin stays high so it can still be read as input
+			want := byte(0xFF)
+			if value == 0 {
+				want &^= 1 << pin
+			}
+			if got := bus.Port(testExpanderAddr); got != want {
+				t.Errorf("pin %d value %d: port = %08b, want %08b", pin, value, got, want)
+			}
+			if got, err := driver.Value(pin); err != nil || got != value {
+				t.Errorf("pin %d: Value = %d, %v, want %d", pin, got, err, value)
+			}
+		}
+	}
+
+	driver := newPCF8574Driver(NewFakeI2CBus(), testExpanderAddr)
+	if err := driver.SetupOutput(8, 0); err == nil {
+		t.Error("SetupOutput(8) succeeded, want an error")
+	}
+}
+
+func TestPCF8574DriverInputs(t *testing.T) {
+	bus := NewFakeI2CBus()
+	bus.AddPortDevice(testExpanderAddr)
+	driver := newPCF8574Driver(bus, testExpanderAddr)
+
+	if err := driver.SetupOutput(0, 0); err != nil {
+		t.Fatalf("SetupOutput: %v", err)
+	}
+	if err := driver.SetupInput(1); err != nil {
+		t.Fatalf("SetupInput: %v", err)
+	}
+
+	// An external signal pulls input pin 1 low
+	bus.SetPort(testExpanderAddr, 0xFC)
+	if got, err := driver.Value(1); err != nil || got != 0 {
+		t.Errorf("Value(1) = %d, %v, want 0", got, err)
+	}
+	bus.SetPort(testExpanderAddr, 0xFE)
+	if got, err := driver.Value(1); err != nil || got != 1 {
+		t.Errorf("Value(1) = %d, %v, want 1", got, err)
+	}
+
+	// Switching the output keeps the input pin high
+	if err := driver.SetValue(0, 1); err != nil {
+		t.Fatalf("SetValue: %v", err)
+	}
+	if got := bus.Port(testExpanderAddr); got != 0xFF {
+		t.Errorf("port = %08b, want %08b", got, 0xFF)
+	}
+}
+
+// assertWrites checks that the bus saw exactly the given writes, in order
+func assertWrites(t *testing.T, bus *FakeI2CBus, want ...[]byte) {
+	t.Helper()
+
+	var writes [][]byte
+	for _, tx := range bus.Transactions() {
+		if tx.Addr != testExpanderAddr {
+			t.Fatalf("transfer to 0x%02x, want 0x%02x", tx.Addr, testExpanderAddr)
+		}
+		if len(tx.Write) > 0 {
+			writes = append(writes, tx.Write)
+		}
+	}
+	if len(writes) != len(want) {
+		t.Fatalf("writes = % x, want % x", writes, want)
+	}
+	for i := range want {
+		if !bytes.Equal(writes[i], want[i]) {
+			t.Fatalf("write %d = % x, want % x", i, writes[i], want[i])
+		}
+	}
+}
@@ -1,280 +1,256 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"sync"
+	"runtime"
 	"time"
-
-	"github.com/warthog618/go-gpiocdev"
 )
 
 // GPIOService handles GPIO pin control for pumps
 type GPIOService struct {
-	chip  *gpiocdev.Chip
-	lines map[int]*gpiocdev.Line
-	mu    sync.Mutex
+	driver PinDriver
 }
 
-// NewGPIOService creates a new GPIO service
-func NewGPIOService() (*GPIOService, error) {
-	// Open the default GPIO chip (gpiochip0 on Raspberry Pi)
-	chip, err := gpiocdev.NewChip("gpiochip0")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open GPIO chip: %w", err)
-	}
+// NewGPIOService creates a new GPIO service on top of a pin driver
+func NewGPIOService(driver PinDriver) *GPIOService {
+	return &GPIOService{driver: driver}
+}
+
+// DriverName returns the name of the pin driver in use
+func (s *GPIOService) DriverName() string {
+	return s.driver.Name()
+}
 
-	return &GPIOService{
-		chip:  chip,
-		lines: make(map[int]*gpiocdev.Line),
-	}, nil
+// Driver returns the underlying pin driver
+func (s *GPIOService) Driver() PinDriver {
+	return s.driver
 }
 
 // Close releases all GPIO resources
 func (s *GPIOService) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Close all open lines
-	for pin, line := range s.lines {
-		if err := line.Close(); err != nil {
-			return fmt.Errorf("failed to close pin %d: %w", pin, err)
-		}
-		delete(s.lines, pin)
-	}
-
-	// Close the chip
-	if s.chip != nil {
-		if err := s.chip.Close(); err != nil {
-			return fmt.Errorf("failed to close GPIO chip: %w", err)
-		}
-	}
-
-	return nil
+	return s.driver.Close()
 }
 
-// SetupOutputPin configures a GPIO pin as output
+// SetupOutputPin configures a GPIO pin as output, starting low
 func (s *GPIOService) SetupOutputPin(pin int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Check if pin is already configured
-	if _, exists := s.lines[pin]; exists {
-		return nil // Already configured
-	}
+	return s.driver.SetupOutput(pin, 0)
+}
 
-	// Request the line as output with initial low value
-	line, err := s.chip.RequestLine(pin, gpiocdev.AsOutput(0))
-	if err != nil {
-		return fmt.Errorf("failed to request pin %d as output: %w", pin, err)
+// setupInactiveOutput configures a GPIO pin as output, starting at its
+// inactive level, so an active low relay does not switch on while the pin
+// is set up
+func (s *GPIOService) setupInactiveOutput(pin int, activeHigh bool) error {
+	value := 1
+	if activeHigh {
+		value = 0
 	}
+	return s.driver.SetupOutput(pin, value)
+}
 
-	s.lines[pin] = line
-	return nil
+// SetupInputPin configures a GPIO pin as input
+func (s *GPIOService) SetupInputPin(pin int) error {
+	return s.driver.SetupInput(pin)
 }
 
 // SetPinHigh sets a GPIO pin to HIGH (3.3V)
 func (s *GPIOService) SetPinHigh(pin int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	line, exists := s.lines[pin]
-	if !exists {
-		return fmt.Errorf("pin %d not configured", pin)
-	}
-
-	if err := line.SetValue(1); err != nil {
+	if err := s.driver.SetValue(pin, 1); err != nil {
 		return fmt.Errorf("failed to set pin %d high: %w", pin, err)
 	}
-
 	return nil
 }
 
 // SetPinLow sets a GPIO pin to LOW (0V)
 func (s *GPIOService) SetPinLow(pin int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	line, exists := s.lines[pin]
-	if !exists {
-		return fmt.Errorf("pin %d not configured", pin)
-	}
-
-	if err := line.SetValue(0); err != nil {
+	if err := s.driver.SetValue(pin, 0); err != nil {
 		return fmt.Errorf("failed to set pin %d low: %w", pin, err)
 	}
-
 	return nil
 }
 
 // GetPinValue reads the current value of a GPIO pin
 func (s *GPIOService) GetPinValue(pin int) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	line, exists := s.lines[pin]
-	if !exists {
-		return 0, fmt.Errorf("pin %d not configured", pin)
-	}
-
-	value, err := line.Value()
+	value, err := s.driver.Value(pin)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read pin %d: %w", pin, err)
 	}
-
 	return value, nil
 }
 
 // PulsePinDuration pulses a pin HIGH for a specific duration
 func (s *GPIOService) PulsePinDuration(pin int, duration time.Duration, activeHigh bool) error {
+	return s.PulsePinDurationContext(context.Background(), pin, duration, activeHigh)
+}
+
+// PulsePinDurationContext pulses a pin for a specific duration or until ctx
+// is cancelled. The pin is always returned to its inactive state.
+func (s *GPIOService) PulsePinDurationContext(ctx context.Context, pin int, duration time.Duration, activeHigh bool) error {
 	// Set pin to active state
-	if activeHigh {
-		if err := s.SetPinHigh(pin); err != nil {
-			return err
-		}
-	} else {
-		if err := s.SetPinLow(pin); err != nil {
-			return err
-		}
+	if err := s.setPinActive(pin, activeHigh, true); err != nil {
+		return err
 	}
 
 	// Wait for duration
-	time.Sleep(duration)
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	var waitErr error
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
 
 	// Set pin to inactive state
-	if activeHigh {
-		if err := s.SetPinLow(pin); err != nil {
-			return err
-		}
-	} else {
-		if err := s.SetPinHigh(pin); err != nil {
-			return err
-		}
+	if err := s.setPinActive(pin, activeHigh, false); err != nil {
+		return err
 	}
 
-	return nil
+	return waitErr
+}
+
+// setPinActive drives a pin to its active or inactive level
+func (s *GPIOService) setPinActive(pin int, activeHigh bool, active bool) error {
+	if activeHigh == active {
+		return s.SetPinHigh(pin)
+	}
+	return s.SetPinLow(pin)
 }
 
 // RunDCPump runs a DC pump for a specified duration
 func (s *GPIOService) RunDCPump(pin int, durationMs int, activeHigh bool) error {
-	if err := s.SetupOutputPin(pin); err != nil {
+	return s.RunDCPumpContext(context.Background(), pin, durationMs, activeHigh)
+}
+
+// RunDCPumpContext runs a DC pump for a specified duration or until ctx is cancelled
+func (s *GPIOService) RunDCPumpContext(ctx context.Context, pin int, durationMs int, activeHigh bool) error {
+	if err := s.setupInactiveOutput(pin, activeHigh); err != nil {
 		return fmt.Errorf("failed to setup DC pump pin: %w", err)
 	}
 
 	duration := time.Duration(durationMs) * time.Millisecond
-	return s.PulsePinDuration(pin, duration, activeHigh)
+	return s.PulsePinDurationContext(ctx, pin, duration, activeHigh)
 }
 
+// defaultMaxStepsPerSecond is used for stepper pumps without a configured speed
+const defaultMaxStepsPerSecond = 1000
+
 // StepperMotorConfig holds configuration for stepper motor control
 type StepperMotorConfig struct {
 	StepPin           int
 	EnablePin         int
+	EnableGPIO        *GPIOService // drives the enable pin, defaults to the step pin's service
 	Steps             int
 	MaxStepsPerSecond int
-	Acceleration      int
+	Acceleration      int // steps/s², 0 starts and stops at full speed
+}
+
+// StepperRun reports how a stepper motor run went
+type StepperRun struct {
+	Steps   int
+	Planned time.Duration
+	Actual  time.Duration
 }
 
+const (
+	// stepPulseWidth is how long a step pulse stays high, drivers need 1-2µs
+	stepPulseWidth = 2 * time.Microsecond
+	// stepSpinThreshold is how close to a step the timing loop stops
+	// sleeping and spins, since sleeping is not precise enough
+	stepSpinThreshold = 200 * time.Microsecond
+)
+
 // RunStepperMotor runs a stepper motor with acceleration profile
-func (s *GPIOService) RunStepperMotor(config StepperMotorConfig) error {
+func (s *GPIOService) RunStepperMotor(config StepperMotorConfig) (StepperRun, error) {
+	return s.RunStepperMotorContext(context.Background(), config)
+}
+
+// RunStepperMotorContext runs a stepper motor along a trapezoidal speed
+// profile until all steps are done or ctx is cancelled. Every step is
+// scheduled relative to the start, so a late step is followed by the next
+// ones right away until the motor is back on schedule. The motor is always
+// disabled again.
+func (s *GPIOService) RunStepperMotorContext(ctx context.Context, config StepperMotorConfig) (run StepperRun, err error) {
+	enable := s
+	if config.EnableGPIO != nil {
+		enable = config.EnableGPIO
+	}
+
 	// Setup pins
 	if err := s.SetupOutputPin(config.StepPin); err != nil {
-		return fmt.Errorf("failed to setup step pin: %w", err)
+		return run, fmt.Errorf("failed to setup step pin: %w", err)
 	}
-	if err := s.SetupOutputPin(config.EnablePin); err != nil {
-		return fmt.Errorf("failed to setup enable pin: %w", err)
+	if err := enable.setupInactiveOutput(config.EnablePin, false); err != nil {
+		return run, fmt.Errorf("failed to setup enable pin: %w", err)
 	}
 
 	// Enable the motor (active LOW for most drivers)
-	if err := s.SetPinLow(config.EnablePin); err != nil {
-		return err
+	if err := enable.SetPinLow(config.EnablePin); err != nil {
+		return run, err
 	}
 
-	// Calculate acceleration profile
-	stepsPerSecond := config.MaxStepsPerSecond
-	if config.Acceleration > 0 {
-		// Simple trapezoidal acceleration profile
-		accelSteps := config.Steps / 4 // 25% acceleration
-		decelSteps := config.Steps / 4 // 25% deceleration
-
-		// Acceleration phase
-		for i := 0; i < accelSteps; i++ {
-			speed := float64(i) / float64(accelSteps) * float64(stepsPerSecond)
-			if speed < 1 {
-				speed = 1
-			}
-			delay := time.Second / time.Duration(speed)
-			if err := s.stepOnce(config.StepPin, delay); err != nil {
-				return err
-			}
-		}
+	motion := planStepperMotion(config.Steps, config.MaxStepsPerSecond, config.Acceleration)
+	run.Planned = motion.Duration()
+	start := time.Now()
 
-		// Constant speed phase
-		constantSteps := config.Steps - accelSteps - decelSteps
-		delay := time.Second / time.Duration(stepsPerSecond)
-		for i := 0; i < constantSteps; i++ {
-			if err := s.stepOnce(config.StepPin, delay); err != nil {
-				return err
-			}
+	// Disable the motor again, whatever happens
+	defer func() {
+		run.Actual = time.Since(start)
+		if disableErr := enable.SetPinHigh(config.EnablePin); disableErr != nil && err == nil {
+			err = disableErr
 		}
+	}()
 
-		// Deceleration phase
-		for i := decelSteps; i > 0; i-- {
-			speed := float64(i) / float64(decelSteps) * float64(stepsPerSecond)
-			if speed < 1 {
-				speed = 1
-			}
-			delay := time.Second / time.Duration(speed)
-			if err := s.stepOnce(config.StepPin, delay); err != nil {
-				return err
-			}
+	for k := 1; k <= config.Steps; k++ {
+		if err := waitUntil(ctx, start.Add(motion.stepTime(k))); err != nil {
+			return run, err
 		}
-	} else {
-		// No acceleration, constant speed
-		delay := time.Second / time.Duration(stepsPerSecond)
-		for i := 0; i < config.Steps; i++ {
-			if err := s.stepOnce(config.StepPin, delay); err != nil {
-				return err
-			}
+		if err := s.stepPulse(config.StepPin); err != nil {
+			return run, err
 		}
+		run.Steps = k
 	}
 
-	// Disable the motor
-	if err := s.SetPinHigh(config.EnablePin); err != nil {
-		return err
-	}
-
-	return nil
+	return run, nil
 }
 
-// stepOnce performs a single step pulse
-func (s *GPIOService) stepOnce(pin int, delay time.Duration) error {
-	// Step pulse (HIGH for 1 microsecond minimum)
+// stepPulse performs a single step pulse
+func (s *GPIOService) stepPulse(pin int) error {
 	if err := s.SetPinHigh(pin); err != nil {
 		return err
 	}
-	time.Sleep(2 * time.Microsecond)
-	if err := s.SetPinLow(pin); err != nil {
+	for pulseStart := time.Now(); time.Since(pulseStart) < stepPulseWidth; {
+	}
+	return s.SetPinLow(pin)
+}
+
+// waitUntil blocks until deadline or until ctx is cancelled. It sleeps
+// while the deadline is far away and spins for the last stretch.
+func waitUntil(ctx context.Context, deadline time.Time) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	// Wait for the step delay
-	time.Sleep(delay)
+	if wait := time.Until(deadline) - stepSpinThreshold; wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
 	return nil
 }
 
 // ReleasePin releases a specific GPIO pin
 func (s *GPIOService) ReleasePin(pin int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	line, exists := s.lines[pin]
-	if !exists {
-		return nil // Already released
-	}
-
-	if err := line.Close(); err != nil {
+	if err := s.driver.Release(pin); err != nil {
 		return fmt.Errorf("failed to release pin %d: %w", pin, err)
 	}
-
-	delete(s.lines, pin)
 	return nil
 }
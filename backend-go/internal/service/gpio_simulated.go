@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxSimulatedTransitions limits how many pin transitions the simulated
+// driver keeps. Stepper motors produce two transitions per step.
+const maxSimulatedTransitions = 10000
+
+// PinTransition is a level change recorded by the simulated pin driver
+type PinTransition struct {
+	Pin   int       `json:"pin"`
+	Value int       `json:"value"`
+	At    time.Time `json:"at"`
+}
+
+// SimulatedPinDriver keeps pin levels in memory and records every
+// transition, so dispensing can be run and inspected without hardware
+type SimulatedPinDriver struct {
+	values      map[int]int
+	transitions []PinTransition
+	mu          sync.Mutex
+}
+
+// NewSimulatedPinDriver creates a new simulated pin driver
+func NewSimulatedPinDriver() *SimulatedPinDriver {
+	return &SimulatedPinDriver{
+		values: make(map[int]int),
+	}
+}
+
+func (d *SimulatedPinDriver) Name() string {
+	return "simulated"
+}
+
+func (d *SimulatedPinDriver) SetupOutput(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.values[pin]; !exists {
+		d.values[pin] = value
+	}
+	return nil
+}
+
+func (d *SimulatedPinDriver) SetupInput(pin int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.values[pin]; !exists {
+		d.values[pin] = 0
+	}
+	return nil
+}
+
+func (d *SimulatedPinDriver) SetValue(pin int, value int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current, exists := d.values[pin]
+	if !exists {
+		return fmt.Errorf("pin %d not configured", pin)
+	}
+	if current == value {
+		return nil
+	}
+
+	d.values[pin] = value
+	d.transitions = append(d.transitions, PinTransition{Pin: pin, Value: value, At: time.Now()})
+	if len(d.transitions) > maxSimulatedTransitions {
+		d.transitions = append(d.transitions[:0], d.transitions[len(d.transitions)-maxSimulatedTransitions:]...)
+	}
+	return nil
+}
+
+func (d *SimulatedPinDriver) Value(pin int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	value, exists := d.values[pin]
+	if !exists {
+		return 0, fmt.Errorf("pin %d not configured", pin)
+	}
+	return value, nil
+}
+
+func (d *SimulatedPinDriver) Release(pin int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.values, pin)
+	return nil
+}
+
+func (d *SimulatedPinDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.values = make(map[int]int)
+	return nil
+}
+
+// Transitions returns the recorded pin transitions, oldest first
+func (d *SimulatedPinDriver) Transitions() []PinTransition {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	transitions := make([]PinTransition, len(d.transitions))
+	copy(transitions, d.transitions)
+	return transitions
+}
+
+// ClearTransitions forgets all recorded pin transitions
+func (d *SimulatedPinDriver) ClearTransitions() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.transitions = nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// hx711Bits is the resolution of an HX711 conversion
+	hx711Bits = 24
+	// hx711GainPulses selects channel A with gain 128 for the next
+	// conversion, the pulse after the data bits
+	hx711GainPulses = 1
+	// hx711ClockWidth is how long SCK stays high or low per bit. SCK must
+	// not stay high for 60µs or the chip powers down.
+	hx711ClockWidth = time.Microsecond
+	// hx711ReadyTimeout is how long to wait for a conversion, the HX711
+	// samples at 10 Hz by default
+	hx711ReadyTimeout = 500 * time.Millisecond
+)
+
+// errHX711NotReady is returned if the HX711 has no conversion ready in time
+var errHX711NotReady = errors.New("HX711 is not responding, check the DT and SCK wiring")
+
+// hx711 reads an HX711 load cell amplifier by bit-banging its DT and SCK
+// pins. Both pins must be on a board that can toggle pins within
+// microseconds, which rules out I2C expanders.
+type hx711 struct {
+	gpio   *GPIOService
+	dtPin  int
+	sckPin int
+}
+
+// setup configures DT as input and SCK as output. A low SCK keeps the
+// chip powered.
+func (h *hx711) setup() error {
+	if err := h.gpio.SetupInputPin(h.dtPin); err != nil {
+		return fmt.Errorf("failed to setup DT pin: %w", err)
+	}
+	if err := h.gpio.SetupOutputPin(h.sckPin); err != nil {
+		return fmt.Errorf("failed to setup SCK pin: %w", err)
+	}
+	return h.gpio.SetPinLow(h.sckPin)
+}
+
+// read returns one raw conversion as a signed 24 bit value
+func (h *hx711) read(ctx context.Context) (int32, error) {
+	if err := h.waitReady(ctx); err != nil {
+		return 0, err
+	}
+
+	var value uint32
+	for i := 0; i < hx711Bits; i++ {
+		if err := h.clock(); err != nil {
+			return 0, err
+		}
+		bit, err := h.gpio.GetPinValue(h.dtPin)
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | uint32(bit)
+	}
+	for i := 0; i < hx711GainPulses; i++ {
+		if err := h.clock(); err != nil {
+			return 0, err
+		}
+	}
+
+	// Sign extend the two's complement value to 32 bit
+	return int32(value<<8) >> 8, nil
+}
+
+// waitReady waits until DT goes low, which signals a finished conversion
+func (h *hx711) waitReady(ctx context.Context) error {
+	deadline := time.Now().Add(hx711ReadyTimeout)
+	for {
+		level, err := h.gpio.GetPinValue(h.dtPin)
+		if err != nil {
+			return err
+		}
+		if level == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errHX711NotReady
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// clock sends a single SCK pulse
+func (h *hx711) clock() error {
+	if err := h.gpio.SetPinHigh(h.sckPin); err != nil {
+		return err
+	}
+	for start := time.Now(); time.Since(start) < hx711ClockWidth; {
+	}
+	if err := h.gpio.SetPinLow(h.sckPin); err != nil {
+		return err
+	}
+	for start := time.Now(); time.Since(start) < hx711ClockWidth; {
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+)
+
+// I2CBus is an I2C bus that GPIO expanders are attached to
+type I2CBus interface {
+	// Tx writes w to the device at addr and then reads len(r) bytes into r.
+	// Either w or r may be empty.
+	Tx(addr uint16, w []byte, r []byte) error
+	// Close closes the bus
+	Close() error
+}
+
+// I2CTransaction is a transfer recorded by the fake I2C bus
+type I2CTransaction struct {
+	Addr  uint16
+	Write []byte
+	Read  []byte
+}
+
+// FakeI2CBus is an in-memory I2C bus for tests and for running without
+// hardware. Register devices behave like the MCP23017: the first written
+// byte selects a register and further bytes are written to consecutive
+// registers. Port devices behave like the PCF8574: every byte is written to
+// or read from the port directly.
+type FakeI2CBus struct {
+	registers    map[uint16]*[256]byte
+	pointers     map[uint16]byte
+	ports        map[uint16]byte
+	transactions []I2CTransaction
+	mu           sync.Mutex
+}
+
+// NewFakeI2CBus creates a new fake I2C bus without devices
+func NewFakeI2CBus() *FakeI2CBus {
+	return &FakeI2CBus{
+		registers: make(map[uint16]*[256]byte),
+		pointers:  make(map[uint16]byte),
+		ports:     make(map[uint16]byte),
+	}
+}
+
+// AddRegisterDevice attaches a register based device at addr
+func (b *FakeI2CBus) AddRegisterDevice(addr uint16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.registers[addr]; !exists {
+		b.registers[addr] = &[256]byte{}
+	}
+}
+
+// AddPortDevice attaches a port device at addr. Its port reads 0xFF until
+// it is written.
+func (b *FakeI2CBus) AddPortDevice(addr uint16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.ports[addr]; !exists {
+		b.ports[addr] = 0xFF
+	}
+}
+
+// Register returns a register of a register device
+func (b *FakeI2CBus) Register(addr uint16, reg byte) byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if registers, exists := b.registers[addr]; exists {
+		return registers[reg]
+	}
+	return 0
+}
+
+// SetRegister sets a register of a register device, for example to
+// simulate an input level
+func (b *FakeI2CBus) SetRegister(addr uint16, reg byte, value byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if registers, exists := b.registers[addr]; exists {
+		registers[reg] = value
+	}
+}
+
+// Port returns the port of a port device
+func (b *FakeI2CBus) Port(addr uint16) byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.ports[addr]
+}
+
+// SetPort sets the port of a port device, for example to simulate an
+// input level
+func (b *FakeI2CBus) SetPort(addr uint16, value byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.ports[addr]; exists {
+		b.ports[addr] = value
+	}
+}
+
+// Transactions returns all recorded transfers
+func (b *FakeI2CBus) Transactions() []I2CTransaction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]I2CTransaction(nil), b.transactions...)
+}
+
+func (b *FakeI2CBus) Tx(addr uint16, w []byte, r []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if registers, exists := b.registers[addr]; exists {
+		if len(w) > 0 {
+			pointer := w[0]
+			for _, value := range w[1:] {
+				registers[pointer] = value
+				pointer++
+			}
+			b.pointers[addr] = w[0]
+		}
+		pointer := b.pointers[addr]
+		for i := range r {
+			r[i] = registers[pointer]
+			pointer++
+		}
+	} else if port, exists := b.ports[addr]; exists {
+		if len(w) > 0 {
+			port = w[len(w)-1]
+			b.ports[addr] = port
+		}
+		for i := range r {
+			r[i] = port
+		}
+	} else {
+		return fmt.Errorf("no device at I2C address 0x%02x", addr)
+	}
+
+	b.transactions = append(b.transactions, I2CTransaction{
+		Addr:  addr,
+		Write: append([]byte(nil), w...),
+		Read:  append([]byte(nil), r...),
+	})
+	return nil
+}
+
+func (b *FakeI2CBus) Close() error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// i2cSlave is the ioctl that selects the device address of an I2C bus file
+const i2cSlave = 0x0703
+
+// linuxI2CBus talks to an I2C bus through /dev/i2c-N
+type linuxI2CBus struct {
+	file *os.File
+	addr uint16
+	mu   sync.Mutex
+}
+
+// OpenI2CBus opens the I2C bus /dev/i2c-<busID>
+func OpenI2CBus(busID int) (I2CBus, error) {
+	file, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", busID), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus %d: %w", busID, err)
+	}
+	return &linuxI2CBus{file: file}, nil
+}
+
+func (b *linuxI2CBus) Tx(addr uint16, w []byte, r []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.addr != addr {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), i2cSlave, uintptr(addr)); errno != 0 {
+			return fmt.Errorf("failed to select I2C address 0x%02x: %w", addr, errno)
+		}
+		b.addr = addr
+	}
+
+	if len(w) > 0 {
+		if _, err := b.file.Write(w); err != nil {
+			return fmt.Errorf("failed to write to I2C address 0x%02x: %w", addr, err)
+		}
+	}
+	if len(r) > 0 {
+		if _, err := b.file.Read(r); err != nil {
+			return fmt.Errorf("failed to read from I2C address 0x%02x: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+func (b *linuxI2CBus) Close() error {
+	return b.file.Close()
+}
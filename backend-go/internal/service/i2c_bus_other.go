@@ -0,0 +1,10 @@
+//go:build !linux
+
+package service
+
+import "errors"
+
+// OpenI2CBus opens the I2C bus /dev/i2c-<busID>, which only exists on Linux
+func OpenI2CBus(busID int) (I2CBus, error) {
+	return nil, errors.New("I2C is only supported on Linux")
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
+)
+
+// SimulatedI2CBus is the fake I2C bus of the simulated driver. Without
+// hardware the expander of an I2C board is attached to the bus the first
+// time its address is used.
+type SimulatedI2CBus struct {
+	*FakeI2CBus
+	repo     *repository.GpioRepository
+	attached map[uint16]bool
+	mu       sync.Mutex
+}
+
+// NewSimulatedI2CBus creates a new simulated I2C bus that attaches the
+// expanders of the I2C boards in repo
+func NewSimulatedI2CBus(repo *repository.GpioRepository) *SimulatedI2CBus {
+	return &SimulatedI2CBus{
+		FakeI2CBus: NewFakeI2CBus(),
+		repo:       repo,
+		attached:   make(map[uint16]bool),
+	}
+}
+
+func (b *SimulatedI2CBus) Tx(addr uint16, w []byte, r []byte) error {
+	if err := b.attach(addr); err != nil {
+		return err
+	}
+	return b.FakeI2CBus.Tx(addr, w, r)
+}
+
+// attach adds an MCP23017 or PCF8574 at addr if an I2C board uses it
+func (b *SimulatedI2CBus) attach(addr uint16) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.attached[addr] {
+		return nil
+	}
+	boards, err := b.repo.FindAllBoards()
+	if err != nil {
+		return fmt.Errorf("failed to find boards: %w", err)
+	}
+	for _, board := range boards {
+		if board.DType != models.GpioBoardI2C || board.BoardModel == nil ||
+			board.I2CAddress == nil || uint16(*board.I2CAddress) != addr {
+			continue
+		}
+		if *board.BoardModel == models.BoardModelPCF8574 {
+			b.AddPortDevice(addr)
+		} else {
+			b.AddRegisterDevice(addr)
+		}
+		b.attached[addr] = true
+	}
+	return nil
+}
@@ -10,12 +10,13 @@ import (
 
 // IngredientService handles business logic for ingredients
 type IngredientService struct {
-	repo *repository.IngredientRepository
+	repo      *repository.IngredientRepository
+	publisher EventPublisher
 }
 
 // NewIngredientService creates a new ingredient service
-func NewIngredientService(repo *repository.IngredientRepository) *IngredientService {
-	return &IngredientService{repo: repo}
+func NewIngredientService(repo *repository.IngredientRepository, publisher EventPublisher) *IngredientService {
+	return &IngredientService{repo: repo, publisher: publisher}
 }
 
 // GetAll returns all ingredients
@@ -72,7 +73,12 @@ func (s *IngredientService) Create(ingredient *models.Ingredient) error {
 		return errors.New("ingredient with this name already exists")
 	}
 
-	return s.repo.Create(ingredient)
+	if err := s.repo.Create(ingredient); err != nil {
+		return err
+	}
+
+	s.publishChange()
+	return nil
 }
 
 // Update updates an existing ingredient
@@ -100,7 +106,12 @@ func (s *IngredientService) Update(ingredient *models.Ingredient) error {
 		return errors.New("ingredient with this name already exists")
 	}
 
-	return s.repo.Update(ingredient)
+	if err := s.repo.Update(ingredient); err != nil {
+		return err
+	}
+
+	s.publishChange()
+	return nil
 }
 
 // Delete deletes an ingredient by ID
@@ -114,7 +125,12 @@ func (s *IngredientService) Delete(id int64) error {
 		return errors.New("ingredient not found")
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	s.publishChange()
+	return nil
 }
 
 // SetInBar updates the inBar status of an ingredient
@@ -128,7 +144,12 @@ func (s *IngredientService) SetInBar(id int64, inBar bool) error {
 		return errors.New("ingredient not found")
 	}
 
-	return s.repo.SetInBar(id, inBar)
+	if err := s.repo.SetInBar(id, inBar); err != nil {
+		return err
+	}
+
+	s.publishChange()
+	return nil
 }
 
 // validateIngredient validates ingredient data
@@ -175,3 +196,11 @@ func (s *IngredientService) validateIngredient(ingredient *models.Ingredient) er
 
 	return nil
 }
+
+// publishChange tells clients to reload recipes, whose availability depends
+// on the ingredients in the bar
+func (s *IngredientService) publishChange() {
+	if s.publisher != nil {
+		s.publisher.InvalidateRecipeScrollCaches()
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
+)
+
+// ErrLoadCellNotConfigured is returned while no load cell is configured
+var ErrLoadCellNotConfigured = errors.New("load cell not configured")
+
+// LoadCellService handles the load cell of the dispensing area: its
+// configuration, calibration and weight readings
+type LoadCellService struct {
+	repo     *repository.LoadCellRepository
+	gpioRepo *repository.GpioRepository
+	boards   *GPIOBoards
+	samples  int
+	zeroRaw  *float64 // first point of a running two-point calibration
+	mu       sync.Mutex
+}
+
+// NewLoadCellService creates a new load cell service
+func NewLoadCellService(cfg *config.Config, repo *repository.LoadCellRepository, gpioRepo *repository.GpioRepository, boards *GPIOBoards) *LoadCellService {
+	return &LoadCellService{
+		repo:     repo,
+		gpioRepo: gpioRepo,
+		boards:   boards,
+		samples:  cfg.LoadCell.Samples,
+	}
+}
+
+// Get returns the load cell, nil if none is configured
+func (s *LoadCellService) Get() (*models.LoadCell, error) {
+	return s.repo.Find()
+}
+
+// Save configures the load cell. There is a single load cell, an existing
+// one is updated.
+func (s *LoadCellService) Save(loadCell *models.LoadCell) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.repo.Find()
+	if err != nil {
+		return fmt.Errorf("failed to find load cell: %w", err)
+	}
+	loadCell.ID = 0
+	if existing != nil {
+		loadCell.ID = existing.ID
+	}
+	if loadCell.ReferenceUnit == 0 {
+		loadCell.ReferenceUnit = 1
+	}
+
+	for _, boardID := range []int64{loadCell.DtPinBoard, loadCell.SckPinBoard} {
+		board, err := s.gpioRepo.FindBoardByID(boardID)
+		if err != nil {
+			return fmt.Errorf("failed to find board: %w", err)
+		}
+		if board != nil && board.DType != models.GpioBoardLocal {
+			return errors.New("load cell pins must be on the local board, I2C expanders are too slow for the HX711")
+		}
+	}
+	assignments := []models.PinUsage{
+		{Board: loadCell.DtPinBoard, PinNr: loadCell.DtPinNr, Type: "loadCell", ID: loadCell.ID, Function: "dtPin"},
+		{Board: loadCell.SckPinBoard, PinNr: loadCell.SckPinNr, Type: "loadCell", ID: loadCell.ID, Function: "sckPin"},
+	}
+	if err := validatePinAssignments(s.gpioRepo, assignments); err != nil {
+		return err
+	}
+
+	s.zeroRaw = nil
+	return s.repo.SaveWithPins(loadCell, assignedPins(assignments))
+}
+
+// Delete removes the load cell
+func (s *LoadCellService) Delete() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loadCell, err := s.repo.Find()
+	if err != nil {
+		return fmt.Errorf("failed to find load cell: %w", err)
+	}
+	if loadCell == nil {
+		return ErrLoadCellNotConfigured
+	}
+
+	s.zeroRaw = nil
+	return s.repo.Delete(loadCell.ID)
+}
+
+// Read measures the current weight
+func (s *LoadCellService) Read(ctx context.Context) (*models.LoadCellReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loadCell, raw, err := s.readRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoadCellReading{
+		Raw:           raw,
+		WeightInGrams: loadCell.Weight(raw),
+		At:            time.Now(),
+	}, nil
+}
+
+// Tare sets the current weight as zero, keeping the calibrated scale
+func (s *LoadCellService) Tare(ctx context.Context) (*models.LoadCell, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loadCell, raw, err := s.readRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loadCell.Offset = raw
+	if err := s.repo.Save(loadCell); err != nil {
+		return nil, fmt.Errorf("failed to save load cell: %w", err)
+	}
+	return loadCell, nil
+}
+
+// CalibrateZero records the first calibration point with an empty
+// dispensing area
+func (s *LoadCellService) CalibrateZero(ctx context.Context) (*models.LoadCellReading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loadCell, raw, err := s.readRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.zeroRaw = &raw
+	return &models.LoadCellReading{
+		Raw:           raw,
+		WeightInGrams: loadCell.Weight(raw),
+		At:            time.Now(),
+	}, nil
+}
+
+// CalibrateReference records the second calibration point with a known
+// weight in grams on the dispensing area and stores the resulting scale
+func (s *LoadCellService) CalibrateReference(ctx context.Context, weightInGrams float64) (*models.LoadCell, error) {
+	if weightInGrams <= 0 {
+		return nil, errors.New("reference weight must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.zeroRaw == nil {
+		return nil, errors.New("calibrate the empty load cell first")
+	}
+
+	loadCell, raw, err := s.readRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	referenceUnit := (raw - *s.zeroRaw) / weightInGrams
+	if referenceUnit == 0 {
+		return nil, errors.New("the reading did not change, check that the reference weight is on the load cell")
+	}
+
+	loadCell.Offset = *s.zeroRaw
+	loadCell.ReferenceUnit = referenceUnit
+	if err := s.repo.Save(loadCell); err != nil {
+		return nil, fmt.Errorf("failed to save load cell: %w", err)
+	}
+	s.zeroRaw = nil
+	return loadCell, nil
+}
+
+// Available returns whether the load cell can be read
+func (s *LoadCellService) Available() bool {
+	return s.boards.Available()
+}
+
+// readRaw returns the load cell with the median of the configured number
+// of raw readings. Must be called with s.mu held.
+func (s *LoadCellService) readRaw(ctx context.Context) (*models.LoadCell, float64, error) {
+	loadCell, err := s.repo.Find()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find load cell: %w", err)
+	}
+	if loadCell == nil {
+		return nil, 0, ErrLoadCellNotConfigured
+	}
+
+	dtGPIO, err := s.boards.Service(&loadCell.DtPinBoard)
+	if err != nil {
+		return nil, 0, err
+	}
+	sckGPIO, err := s.boards.Service(&loadCell.SckPinBoard)
+	if err != nil {
+		return nil, 0, err
+	}
+	if dtGPIO != sckGPIO {
+		return nil, 0, errors.New("DT and SCK pins must be on the same board")
+	}
+
+	sensor := &hx711{gpio: dtGPIO, dtPin: loadCell.DtPinNr, sckPin: loadCell.SckPinNr}
+	if err := sensor.setup(); err != nil {
+		return nil, 0, err
+	}
+
+	readings := make([]float64, 0, s.samples)
+	for len(readings) < s.samples {
+		value, err := sensor.read(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		readings = append(readings, float64(value))
+	}
+
+	// The median ignores single glitched conversions
+	sort.Float64s(readings)
+	middle := len(readings) / 2
+	if len(readings)%2 == 0 {
+		return loadCell, (readings[middle-1] + readings[middle]) / 2, nil
+	}
+	return loadCell, readings[middle], nil
+}
@@ -0,0 +1,552 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+const (
+	// calibrationRunTime is how long a calibration run takes by default.
+	// Longer runs make the measuring error smaller.
+	calibrationRunTime = 10 * time.Second
+	// calibrationMaxRunTime is the longest calibration run
+	calibrationMaxRunTime = 2 * time.Minute
+	// calibrationSettleTime is how long the load cell waits for the last
+	// drops after a run
+	calibrationSettleTime = 2 * time.Second
+	// valveCalibrationLevelSpread is how many ml the levels of the runs of a
+	// valve pump have to span to measure its head offset
+	valveCalibrationLevelSpread = 100
+)
+
+// pumpCalibration is a calibration in progress together with the pump as
+// it was when the calibration started. The filling level of a valve pump is
+// lowered by every measured run and saved when the calibration ends.
+type pumpCalibration struct {
+	state  models.PumpCalibration
+	pump   *models.Pump
+	cancel context.CancelFunc
+}
+
+// PumpCalibrationService calibrates pumps: it runs a pump for a fixed time
+// or number of steps, takes the measured amount and computes TimePerClInMs
+// or StepsPerCl, or the flow model of a valve pump. Run with an ingredient
+// instead of water on a calibrated pump it suggests the ingredient's
+// PumpTimeMultiplier. The amount can be measured by hand or by the load
+// cell, which counts a gram as a millilitre.
+type PumpCalibrationService struct {
+	pumps        *PumpService
+	ingredients  *IngredientService
+	runtime      *PumpRuntime
+	loadCells    *LoadCellService
+	calibrations map[int64]*pumpCalibration
+	mu           sync.Mutex
+}
+
+// NewPumpCalibrationService creates a new pump calibration service
+func NewPumpCalibrationService(pumps *PumpService, ingredients *IngredientService, runtime *PumpRuntime, loadCells *LoadCellService) *PumpCalibrationService {
+	return &PumpCalibrationService{
+		pumps:        pumps,
+		ingredients:  ingredients,
+		runtime:      runtime,
+		loadCells:    loadCells,
+		calibrations: make(map[int64]*pumpCalibration),
+	}
+}
+
+// Get returns the calibration of a pump
+func (s *PumpCalibrationService) Get(pumpID int64) (*models.PumpCalibration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calibration, ok := s.calibrations[pumpID]
+	if !ok {
+		return nil, errors.New("calibration not started")
+	}
+	return calibration.snapshot(), nil
+}
+
+// Start starts calibrating a pump, discarding an earlier calibration.
+// With an ingredient the pump must already be calibrated.
+func (s *PumpCalibrationService) Start(pumpID int64, ingredientID *int64) (*models.PumpCalibration, error) {
+	pump, err := s.pumps.GetByID(pumpID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pump: %w", err)
+	}
+	if pump == nil {
+		return nil, errors.New("pump not found")
+	}
+	switch pump.DType {
+	case "DcPump", "StepperPump":
+	case "ValvePump":
+		// The flow of a valve depends on the level, so it has to be known
+		if pump.FillingLevelInMl <= 0 {
+			return nil, errors.New("set the filling level to the liquid in the bottle first")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported pump type: %s", pump.DType)
+	}
+
+	if ingredientID != nil {
+		ingredient, err := s.ingredients.GetByID(*ingredientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find ingredient: %w", err)
+		}
+		if ingredient == nil {
+			return nil, errors.New("ingredient not found")
+		}
+		if !isAutomated(ingredient) {
+			return nil, errors.New("only automated ingredients have a pump time multiplier")
+		}
+		if !pumpCalibrated(pump) {
+			return nil, errors.New("calibrate the pump with water first")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.calibrations[pumpID]; ok && existing.state.Running {
+		return nil, errors.New("a calibration run is in progress")
+	}
+
+	calibration := &pumpCalibration{
+		state: models.PumpCalibration{
+			PumpID:       pumpID,
+			IngredientID: ingredientID,
+			Runs:         []models.PumpCalibrationRun{},
+		},
+		pump: pump,
+	}
+	s.calibrations[pumpID] = calibration
+	return calibration.snapshot(), nil
+}
+
+// Run starts a calibration run in the background. DC pumps run for
+// durationMs, stepper pumps for steps; zero picks a default. With the load
+// cell the dispensed amount is weighed, otherwise it has to be entered with
+// Measure once the run is done.
+func (s *PumpCalibrationService) Run(ctx context.Context, pumpID int64, durationMs int, steps int, useLoadCell bool) (*models.PumpCalibration, error) {
+	// Reading the load cell takes a while, so it is done before locking
+	var before *models.LoadCellReading
+	if useLoadCell {
+		reading, err := s.loadCells.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		before = reading
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calibration, ok := s.calibrations[pumpID]
+	if !ok {
+		return nil, errors.New("calibration not started")
+	}
+	if calibration.state.Running {
+		return nil, errors.New("a calibration run is in progress")
+	}
+	if runs := calibration.state.Runs; len(runs) > 0 && runs[len(runs)-1].MeasuredMl == nil {
+		return nil, errors.New("enter the measured amount of the last run first")
+	}
+	if !s.runtime.Available() {
+		return nil, errGPIONotAvailable
+	}
+
+	pump := calibration.pump
+	run := models.PumpCalibrationRun{LoadCell: useLoadCell}
+	switch pump.DType {
+	case "DcPump", "ValvePump":
+		if pump.DType == "ValvePump" {
+			if pump.FillingLevelInMl <= 0 {
+				return nil, errors.New("the bottle is empty, refill it and start again")
+			}
+			run.LevelInMl = pump.FillingLevelInMl
+		}
+		if durationMs == 0 {
+			durationMs = int(calibrationRunTime.Milliseconds())
+		}
+		if durationMs < 0 || time.Duration(durationMs)*time.Millisecond > calibrationMaxRunTime {
+			return nil, fmt.Errorf("run duration must be between 1 and %d ms", calibrationMaxRunTime.Milliseconds())
+		}
+		run.DurationMs = durationMs
+	case "StepperPump":
+		stepsPerSecond := defaultMaxStepsPerSecond
+		if pump.MaxStepsPerSecond != nil {
+			stepsPerSecond = *pump.MaxStepsPerSecond
+		}
+		if steps == 0 {
+			steps = int(calibrationRunTime.Seconds()) * stepsPerSecond
+		}
+		maxSteps := int(calibrationMaxRunTime.Seconds()) * stepsPerSecond
+		if steps < 0 || steps > maxSteps {
+			return nil, fmt.Errorf("steps must be between 1 and %d", maxSteps)
+		}
+		run.Steps = steps
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	calibration.cancel = cancel
+	calibration.state.Runs = append(calibration.state.Runs, run)
+	calibration.state.Running = true
+	calibration.state.Error = ""
+
+	go s.run(runCtx, calibration, run, before)
+
+	return calibration.snapshot(), nil
+}
+
+// run drives the pump for a calibration run and weighs the result if the
+// run uses the load cell
+func (s *PumpCalibrationService) run(ctx context.Context, calibration *pumpCalibration, run models.PumpCalibrationRun, before *models.LoadCellReading) {
+	ctx = withPumpJob(ctx, "calibration")
+
+	var err error
+	if run.Steps > 0 {
+		err = s.runtime.RunSteps(ctx, calibration.pump, run.Steps)
+	} else {
+		err = s.runtime.RunFor(ctx, calibration.pump, time.Duration(run.DurationMs)*time.Millisecond)
+	}
+
+	var measuredMl *float64
+	if err == nil && before != nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(calibrationSettleTime):
+			var after *models.LoadCellReading
+			if after, err = s.loadCells.Read(ctx); err == nil {
+				ml := after.WeightInGrams - before.WeightInGrams
+				if ml > 0 {
+					measuredMl = &ml
+				} else {
+					err = errors.New("the load cell measured no liquid")
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calibration.cancel()
+	calibration.cancel = nil
+	calibration.state.Running = false
+	if s.calibrations[calibration.state.PumpID] != calibration {
+		// Cancelled while running
+		return
+	}
+
+	last := len(calibration.state.Runs) - 1
+	if err != nil {
+		calibration.state.Error = err.Error()
+		calibration.state.Runs = calibration.state.Runs[:last]
+		return
+	}
+	if measuredMl != nil {
+		calibration.measure(*measuredMl)
+	}
+}
+
+// Measure records the amount dispensed by the last run
+func (s *PumpCalibrationService) Measure(pumpID int64, measuredMl float64) (*models.PumpCalibration, error) {
+	if measuredMl <= 0 {
+		return nil, errors.New("measured amount must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calibration, ok := s.calibrations[pumpID]
+	if !ok {
+		return nil, errors.New("calibration not started")
+	}
+	if calibration.state.Running {
+		return nil, errors.New("a calibration run is in progress")
+	}
+	if len(calibration.state.Runs) == 0 {
+		return nil, errors.New("no calibration run to measure")
+	}
+
+	calibration.measure(measuredMl)
+	calibration.state.Runs[len(calibration.state.Runs)-1].LoadCell = false
+	return calibration.snapshot(), nil
+}
+
+// Apply saves the result of the calibration to the pump, or to the
+// ingredient when an ingredient was calibrated, and ends the calibration
+func (s *PumpCalibrationService) Apply(pumpID int64) (*models.PumpCalibrationResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calibration, ok := s.calibrations[pumpID]
+	if !ok {
+		return nil, errors.New("calibration not started")
+	}
+	if calibration.state.Running {
+		return nil, errors.New("a calibration run is in progress")
+	}
+	result := calibration.result()
+	if result == nil {
+		return nil, errors.New("no measured calibration run")
+	}
+
+	switch {
+	case result.PumpTimeMultiplier != nil:
+		ingredient, err := s.ingredients.GetByID(*calibration.state.IngredientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find ingredient: %w", err)
+		}
+		if ingredient == nil {
+			return nil, errors.New("ingredient not found")
+		}
+		ingredient.PumpTimeMultiplier = result.PumpTimeMultiplier
+		if err := s.ingredients.Update(ingredient); err != nil {
+			return nil, err
+		}
+	case result.ValveFlowMlPerSec != nil:
+		fields := map[string]interface{}{
+			"valve_flow_ml_per_sec":       *result.ValveFlowMlPerSec,
+			"valve_reference_level_in_ml": *result.ValveReferenceLevelInMl,
+		}
+		if result.ValveHeadOffsetInMl != nil {
+			fields["valve_head_offset_in_ml"] = *result.ValveHeadOffsetInMl
+		}
+		if err := s.pumps.UpdateFields(pumpID, fields); err != nil {
+			return nil, err
+		}
+	case result.TimePerClInMs != nil:
+		if err := s.pumps.UpdateFields(pumpID, map[string]interface{}{"time_per_cl_in_ms": *result.TimePerClInMs}); err != nil {
+			return nil, err
+		}
+	case result.StepsPerCl != nil:
+		if err := s.pumps.UpdateFields(pumpID, map[string]interface{}{"steps_per_cl": *result.StepsPerCl}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.saveFillingLevel(calibration); err != nil {
+		return nil, err
+	}
+
+	delete(s.calibrations, pumpID)
+	return result, nil
+}
+
+// Cancel ends the calibration of a pump, stopping a running run
+func (s *PumpCalibrationService) Cancel(pumpID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calibration, ok := s.calibrations[pumpID]
+	if !ok {
+		return errors.New("calibration not started")
+	}
+	if calibration.cancel != nil {
+		calibration.cancel()
+	}
+	delete(s.calibrations, pumpID)
+	return s.saveFillingLevel(calibration)
+}
+
+// saveFillingLevel saves the filling level of a valve pump after its
+// measured runs took liquid from the bottle. Must be called with the
+// service mutex held.
+func (s *PumpCalibrationService) saveFillingLevel(calibration *pumpCalibration) error {
+	if calibration.pump.DType != "ValvePump" {
+		return nil
+	}
+	for _, run := range calibration.state.Runs {
+		if run.MeasuredMl != nil {
+			return s.pumps.SetFillingLevel(calibration.state.PumpID, calibration.pump.FillingLevelInMl)
+		}
+	}
+	return nil
+}
+
+// snapshot returns a copy of the calibration state. Must be called with
+// the service mutex held.
+func (c *pumpCalibration) snapshot() *models.PumpCalibration {
+	state := c.state
+	state.Runs = make([]models.PumpCalibrationRun, len(c.state.Runs))
+	copy(state.Runs, c.state.Runs)
+	return &state
+}
+
+// measure records the amount dispensed by the last run. Must be called
+// with the service mutex held.
+func (c *pumpCalibration) measure(measuredMl float64) {
+	last := &c.state.Runs[len(c.state.Runs)-1]
+	last.MeasuredMl = &measuredMl
+	if c.pump.DType == "ValvePump" {
+		c.pump.FillingLevelInMl = max(last.LevelInMl-int(math.Round(measuredMl)), 0)
+	}
+	c.state.Result = c.result()
+}
+
+// result averages the measured runs, weighted by their amount, so longer
+// runs count more. Returns nil without measured runs.
+func (c *pumpCalibration) result() *models.PumpCalibrationResult {
+	if c.pump.DType == "ValvePump" {
+		return c.valveResult()
+	}
+
+	var units, measured float64
+	minRate, maxRate := math.Inf(1), math.Inf(-1)
+	result := &models.PumpCalibrationResult{}
+	for _, run := range c.state.Runs {
+		if run.MeasuredMl == nil {
+			continue
+		}
+		runUnits := float64(run.DurationMs + run.Steps)
+		rate := runUnits / *run.MeasuredMl
+		minRate = math.Min(minRate, rate)
+		maxRate = math.Max(maxRate, rate)
+		units += runUnits
+		measured += *run.MeasuredMl
+		result.Runs++
+	}
+	if result.Runs == 0 {
+		return nil
+	}
+
+	perCl := units / measured * 10
+	result.SpreadPercent = math.Round((maxRate-minRate)/(perCl/10)*1000) / 10
+
+	if c.state.IngredientID != nil {
+		// The pump's own calibration says how much water the runs would
+		// have dispensed, the ingredient needs that much more time
+		multiplier := math.Round(perCl/float64(pumpUnitsPerCl(c.pump))*100) / 100
+		result.PumpTimeMultiplier = &multiplier
+		return result
+	}
+
+	value := int(math.Max(1, math.Round(perCl)))
+	if c.pump.DType == "StepperPump" {
+		result.StepsPerCl = &value
+	} else {
+		result.TimePerClInMs = &value
+	}
+	return result
+}
+
+// valveResult fits the flow model of a valve pump to the measured runs.
+// Every run gives the average flow at the level halfway through it. The
+// squared flow grows in a line with the level, and the line reaches zero
+// at the head offset below the bottle. Runs at about the same level only
+// give the flow, and the head offset of the pump is kept. Returns nil
+// without measured runs.
+func (c *pumpCalibration) valveResult() *models.PumpCalibrationResult {
+	type point struct{ level, flow, weight float64 }
+	var points []point
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, run := range c.state.Runs {
+		if run.MeasuredMl == nil || run.DurationMs <= 0 {
+			continue
+		}
+		level := math.Max(float64(run.LevelInMl)-*run.MeasuredMl/2, 1)
+		flow := *run.MeasuredMl / (float64(run.DurationMs) / 1000)
+		points = append(points, point{level: level, flow: flow, weight: *run.MeasuredMl})
+		lowest = math.Min(lowest, level)
+		highest = math.Max(highest, level)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	result := &models.PumpCalibrationResult{Runs: len(points)}
+
+	if c.state.IngredientID != nil {
+		// The pump's flow model says how much water the runs would have
+		// poured, the ingredient needs that much more time
+		flow, err := newValveFlow(c.pump)
+		if err != nil {
+			return nil
+		}
+		var water, measured float64
+		minRatio, maxRatio := math.Inf(1), math.Inf(-1)
+		for _, run := range c.state.Runs {
+			if run.MeasuredMl == nil || run.DurationMs <= 0 {
+				continue
+			}
+			expected := flow.poured(float64(run.LevelInMl), time.Duration(run.DurationMs)*time.Millisecond)
+			minRatio = math.Min(minRatio, expected / *run.MeasuredMl)
+			maxRatio = math.Max(maxRatio, expected / *run.MeasuredMl)
+			water += expected
+			measured += *run.MeasuredMl
+		}
+		multiplier := math.Round(water/measured*100) / 100
+		result.PumpTimeMultiplier = &multiplier
+		result.SpreadPercent = math.Round((maxRatio-minRatio)/(water/measured)*1000) / 10
+		return result
+	}
+
+	var weights, levels, squares float64
+	for _, p := range points {
+		weights += p.weight
+		levels += p.weight * p.level
+		squares += p.weight * p.flow * p.flow
+	}
+	meanLevel, meanSquare := levels/weights, squares/weights
+
+	var offset float64
+	if c.pump.ValveHeadOffsetInMl != nil {
+		offset = float64(*c.pump.ValveHeadOffsetInMl)
+	}
+	if highest-lowest >= valveCalibrationLevelSpread {
+		var sxx, sxy float64
+		for _, p := range points {
+			dx := p.level - meanLevel
+			sxx += p.weight * dx * dx
+			sxy += p.weight * dx * (p.flow*p.flow - meanSquare)
+		}
+		// A flow that does not drop with the level is measuring noise
+		if sxy > 0 {
+			offset = math.Max(meanSquare/(sxy/sxx)-meanLevel, 0)
+			headOffset := int(math.Round(offset))
+			result.ValveHeadOffsetInMl = &headOffset
+		}
+	}
+
+	flow := math.Round(math.Sqrt(meanSquare)*100) / 100
+	level := int(math.Round(meanLevel))
+	result.ValveFlowMlPerSec = &flow
+	result.ValveReferenceLevelInMl = &level
+
+	// Runs disagree by how far their flows differ at the same level
+	minFlow, maxFlow := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		normalized := p.flow * math.Sqrt((meanLevel+offset)/(p.level+offset))
+		minFlow = math.Min(minFlow, normalized)
+		maxFlow = math.Max(maxFlow, normalized)
+	}
+	result.SpreadPercent = math.Round((maxFlow-minFlow)/math.Sqrt(meanSquare)*1000) / 10
+	return result
+}
+
+// pumpCalibrated reports whether a pump has a calibration to compare an
+// ingredient against
+func pumpCalibrated(pump *models.Pump) bool {
+	if pump.DType == "ValvePump" {
+		_, err := newValveFlow(pump)
+		return err == nil
+	}
+	return pumpUnitsPerCl(pump) != 0
+}
+
+// pumpUnitsPerCl returns the calibration of a pump in milliseconds or steps
+// per centilitre, 0 if the pump is not calibrated
+func pumpUnitsPerCl(pump *models.Pump) int {
+	switch {
+	case pump.DType == "DcPump" && pump.TimePerClInMs != nil:
+		return *pump.TimePerClInMs
+	case pump.DType == "StepperPump" && pump.StepsPerCl != nil:
+		return *pump.StepsPerCl
+	}
+	return 0
+}
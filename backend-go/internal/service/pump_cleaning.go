@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+const (
+	// cleaningMaxRepeat is how often a cleaning pattern can be repeated at most
+	cleaningMaxRepeat = 20
+	// cleaningMaxSoak is the longest soak step
+	cleaningMaxSoak = 30 * time.Minute
+	// cleaningMaxAmount is the largest amount a single pulse may move
+	cleaningMaxAmount = 1000
+)
+
+// defaultCleaningSteps flushes the tubes, lets the cleaning liquid soak and
+// pulls it back, three times over
+var defaultCleaningSteps = []models.PumpCleaningStep{
+	{Action: "forward"},
+	{Action: "soak", DurationMs: 30000},
+	{Action: "reverse"},
+}
+
+const defaultCleaningRepeat = 3
+
+// pumpCleaning is a cleaning program together with the pumps as they were
+// when it was created
+type pumpCleaning struct {
+	state  models.PumpCleaning
+	pumps  []*models.Pump
+	cancel context.CancelFunc
+}
+
+// PumpCleaningService runs cleaning programs: selected pumps run a pattern
+// of forward pulses, soak times and reverse pulses with a cleaning liquid
+// loaded. A program has to be confirmed before it starts. Cocktail
+// production waits while a program runs.
+type PumpCleaningService struct {
+	pumps          *PumpService
+	runtime        *PumpRuntime
+	publisher      EventPublisher
+	cleaning       *pumpCleaning
+	productionBusy func() bool
+	onFinish       []func()
+	mu             sync.Mutex
+}
+
+// NewPumpCleaningService creates a new pump cleaning service
+func NewPumpCleaningService(pumps *PumpService, runtime *PumpRuntime, publisher EventPublisher) *PumpCleaningService {
+	s := &PumpCleaningService{
+		pumps:     pumps,
+		runtime:   runtime,
+		publisher: publisher,
+	}
+
+	runtime.OnEmergencyStop(s.abortForEmergencyStop)
+
+	return s
+}
+
+// OnFinish registers a function that is called after a cleaning program ends
+func (s *PumpCleaningService) OnFinish(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onFinish = append(s.onFinish, fn)
+}
+
+// waitForProduction makes Confirm refuse to start while busy reports that a
+// cocktail is being made
+func (s *PumpCleaningService) waitForProduction(busy func() bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.productionBusy = busy
+}
+
+// Running reports whether a cleaning program is running
+func (s *PumpCleaningService) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cleaning != nil && s.cleaning.state.Status == "running"
+}
+
+// Get returns the current or last cleaning program
+func (s *PumpCleaningService) Get() (*models.PumpCleaning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cleaning == nil {
+		return nil, errors.New("no cleaning program")
+	}
+	return s.cleaning.snapshot(), nil
+}
+
+// Create sets up a pending cleaning program for the given pumps. Without
+// steps the default pattern is used. The program starts once it is confirmed.
+func (s *PumpCleaningService) Create(pumpIDs []int64, cleanedWith string, steps []models.PumpCleaningStep, repeat int) (*models.PumpCleaning, error) {
+	if len(pumpIDs) == 0 {
+		return nil, errors.New("select at least one pump")
+	}
+	cleanedWith = strings.TrimSpace(cleanedWith)
+	if cleanedWith == "" {
+		cleanedWith = "water"
+	}
+	if len(steps) == 0 {
+		steps = defaultCleaningSteps
+		if repeat == 0 {
+			repeat = defaultCleaningRepeat
+		}
+	}
+	if repeat == 0 {
+		repeat = 1
+	}
+	if repeat < 0 || repeat > cleaningMaxRepeat {
+		return nil, fmt.Errorf("repeat must be between 1 and %d", cleaningMaxRepeat)
+	}
+
+	ids := make([]int64, 0, len(pumpIDs))
+	pumps := make([]*models.Pump, 0, len(pumpIDs))
+	states := make([]models.PumpCleaningState, 0, len(pumpIDs))
+	seen := make(map[int64]bool)
+	for _, id := range pumpIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		pump, err := s.pumps.GetByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find pump: %w", err)
+		}
+		if pump == nil {
+			return nil, errors.New("pump not found")
+		}
+		ids = append(ids, pump.ID)
+		pumps = append(pumps, pump)
+		states = append(states, models.PumpCleaningState{
+			PumpID:   pump.ID,
+			PumpName: pumpDisplayName(pump),
+			Status:   "waiting",
+		})
+	}
+
+	for _, step := range steps {
+		if err := validateCleaningStep(step, pumps); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cleaning != nil && s.cleaning.state.Status == "running" {
+		return nil, errors.New("a cleaning program is running")
+	}
+
+	names := make([]string, len(states))
+	for i := range states {
+		names[i] = states[i].PumpName
+	}
+	s.cleaning = &pumpCleaning{
+		state: models.PumpCleaning{
+			PumpIDs:     ids,
+			CleanedWith: cleanedWith,
+			Steps:       steps,
+			Repeat:      repeat,
+			Status:      "pending",
+			Message: fmt.Sprintf("Load %s on pump %s and put a container in the dispensing area, then confirm",
+				cleanedWith, strings.Join(names, ", ")),
+			TotalSteps: len(steps) * repeat,
+			Pumps:      states,
+		},
+		pumps: pumps,
+	}
+	s.publish(s.cleaning.snapshot())
+	return s.cleaning.snapshot(), nil
+}
+
+// Confirm starts the pending cleaning program in the background
+func (s *PumpCleaningService) Confirm() (*models.PumpCleaning, error) {
+	if !s.runtime.Available() {
+		return nil, errGPIONotAvailable
+	}
+	if s.runtime.IsStopped() {
+		return nil, errPumpsStopped
+	}
+
+	s.mu.Lock()
+	cleaning := s.cleaning
+	if cleaning == nil || cleaning.state.Status != "pending" {
+		s.mu.Unlock()
+		return nil, errors.New("no cleaning program to confirm")
+	}
+	for _, pump := range cleaning.pumps {
+		if s.runtime.IsRunning(pump.ID) {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("pump %s is running", pumpDisplayName(pump))
+		}
+	}
+
+	// Claim the pumps before asking for production, so no order can start
+	// in between: production checks Running before it starts an order
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	cleaning.cancel = cancel
+	cleaning.state.Status = "running"
+	cleaning.state.Message = "Cleaning"
+	cleaning.state.StartedAt = &now
+	busy := s.productionBusy
+	s.mu.Unlock()
+
+	if busy != nil && busy() {
+		s.mu.Lock()
+		cancel()
+		cleaning.cancel = nil
+		cleaning.state.Status = "pending"
+		cleaning.state.StartedAt = nil
+		s.mu.Unlock()
+		return nil, errors.New("wait until the current cocktail is done")
+	}
+
+	go s.run(ctx, cleaning)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publish(cleaning.snapshot())
+	return cleaning.snapshot(), nil
+}
+
+// Cancel discards a pending cleaning program or stops a running one
+func (s *PumpCleaningService) Cancel() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cleaning := s.cleaning
+	if cleaning == nil || (cleaning.state.Status != "pending" && cleaning.state.Status != "running") {
+		return errors.New("no cleaning program to cancel")
+	}
+	if cleaning.cancel != nil {
+		cleaning.cancel()
+		return nil
+	}
+
+	now := time.Now()
+	cleaning.state.Status = "cancelled"
+	cleaning.state.Message = "Cleaning cancelled"
+	cleaning.state.CompletedAt = &now
+	s.publish(cleaning.snapshot())
+	return nil
+}
+
+// run works through the pattern and records the cleaning on every pump
+// that finished it
+func (s *PumpCleaningService) run(ctx context.Context, cleaning *pumpCleaning) {
+	ctx = withPumpJob(ctx, "cleaning")
+	failed := make(map[int64]bool)
+
+	var err error
+	stepNr := 0
+	for round := 1; round <= cleaning.state.Repeat && err == nil; round++ {
+		for _, step := range cleaning.state.Steps {
+			stepNr++
+			s.update(cleaning, func(state *models.PumpCleaning) {
+				state.CurrentStep = stepNr
+				state.PercentDone = (stepNr - 1) * 100 / state.TotalSteps
+				state.Message = fmt.Sprintf("Round %d of %d: %s", round, state.Repeat, cleaningStepLabel(step))
+			})
+
+			if err = s.runStep(ctx, cleaning, step, failed); err != nil {
+				break
+			}
+			if len(failed) == len(cleaning.pumps) {
+				err = errors.New("all pumps failed")
+				break
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, pump := range cleaning.pumps {
+		if err != nil || failed[pump.ID] {
+			continue
+		}
+		if err := s.pumps.UpdateFields(pump.ID, map[string]interface{}{
+			"last_cleaned_at":   now,
+			"last_cleaned_with": cleaning.state.CleanedWith,
+			"is_pumped_up":      false,
+		}); err != nil {
+			log.Printf("Failed to record cleaning of pump %s: %v", pumpDisplayName(pump), err)
+		}
+	}
+
+	s.mu.Lock()
+	cleaning.cancel()
+	cleaning.cancel = nil
+	cleaning.state.CompletedAt = &now
+	switch {
+	case errors.Is(err, context.Canceled):
+		cleaning.state.Status = "cancelled"
+		cleaning.state.Message = "Cleaning cancelled"
+	case err != nil:
+		cleaning.state.Status = "error"
+		cleaning.state.Message = "Cleaning failed"
+		cleaning.state.Error = err.Error()
+	default:
+		cleaning.state.Status = "done"
+		cleaning.state.Message = "Cleaning done, load the ingredients and pump up again"
+		cleaning.state.PercentDone = 100
+	}
+	for i := range cleaning.state.Pumps {
+		if cleaning.state.Pumps[i].Status == "running" || cleaning.state.Pumps[i].Status == "waiting" {
+			cleaning.state.Pumps[i].Status = cleaning.state.Status
+		}
+	}
+	s.publish(cleaning.snapshot())
+	hooks := append([]func(){}, s.onFinish...)
+	s.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// runStep runs a single step of the pattern on all pumps that have not
+// failed yet. Pumps that fail are recorded and left out of later steps.
+func (s *PumpCleaningService) runStep(ctx context.Context, cleaning *pumpCleaning, step models.PumpCleaningStep, failed map[int64]bool) error {
+	if step.Action == "soak" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(step.DurationMs) * time.Millisecond):
+			return nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, pump := range cleaning.pumps {
+		if failed[pump.ID] {
+			continue
+		}
+		if step.Action == "reverse" && !pump.IsReversible() {
+			s.setPumpState(cleaning, i, "running", "Skipped reverse, the pump cannot run backwards")
+			continue
+		}
+
+		amountMl := step.AmountInMl
+		if amountMl == 0 {
+			amountMl = int(math.Round(*pump.TubeCapacity))
+		}
+
+		s.setPumpState(cleaning, i, "running", "")
+		wg.Add(1)
+		go func(i int, pump *models.Pump) {
+			defer wg.Done()
+
+			run := s.runtime.Dispense
+			if step.Action == "reverse" {
+				run = s.runtime.Reverse
+			}
+			if err := run(ctx, pump, nil, amountMl); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Pump %s failed while cleaning: %v", pumpDisplayName(pump), err)
+				s.mu.Lock()
+				failed[pump.ID] = true
+				s.mu.Unlock()
+				s.setPumpState(cleaning, i, "error", err.Error())
+			}
+		}(i, pump)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// abortForEmergencyStop stops a running cleaning program
+func (s *PumpCleaningService) abortForEmergencyStop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cleaning != nil && s.cleaning.cancel != nil {
+		s.cleaning.cancel()
+	}
+}
+
+// setPumpState updates the state of a pump in a cleaning program
+func (s *PumpCleaningService) setPumpState(cleaning *pumpCleaning, index int, status string, message string) {
+	s.update(cleaning, func(state *models.PumpCleaning) {
+		state.Pumps[index].Status = status
+		state.Pumps[index].Message = message
+	})
+}
+
+// update changes the state of a cleaning program and publishes it
+func (s *PumpCleaningService) update(cleaning *pumpCleaning, change func(state *models.PumpCleaning)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change(&cleaning.state)
+	s.publish(cleaning.snapshot())
+}
+
+// publish pushes the state of a cleaning program to all clients. Must be
+// called with s.mu held.
+func (s *PumpCleaningService) publish(state *models.PumpCleaning) {
+	if s.publisher == nil {
+		return
+	}
+	s.publisher.BroadcastPumpCleaning(state)
+}
+
+// snapshot returns a copy of the cleaning state. Must be called with the
+// service mutex held.
+func (c *pumpCleaning) snapshot() *models.PumpCleaning {
+	state := c.state
+	state.Pumps = make([]models.PumpCleaningState, len(c.state.Pumps))
+	copy(state.Pumps, c.state.Pumps)
+	return &state
+}
+
+// validateCleaningStep checks a step of a cleaning pattern against the
+// pumps it runs on
+func validateCleaningStep(step models.PumpCleaningStep, pumps []*models.Pump) error {
+	switch step.Action {
+	case "soak":
+		if step.DurationMs <= 0 || time.Duration(step.DurationMs)*time.Millisecond > cleaningMaxSoak {
+			return fmt.Errorf("soak time must be between 1 ms and %s", cleaningMaxSoak)
+		}
+		return nil
+
+	case "forward", "reverse":
+		if step.AmountInMl < 0 || step.AmountInMl > cleaningMaxAmount {
+			return fmt.Errorf("pulse amount must be between 0 and %d ml", cleaningMaxAmount)
+		}
+		for _, pump := range pumps {
+			if step.AmountInMl == 0 && (pump.TubeCapacity == nil || *pump.TubeCapacity <= 0) {
+				return fmt.Errorf("pump %s has no tube capacity, set the pulse amount", pumpDisplayName(pump))
+			}
+			if _, err := pumpRunDuration(pump, nil, max(step.AmountInMl, 1)); err != nil {
+				return fmt.Errorf("pump %s: %w", pumpDisplayName(pump), err)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown cleaning step: %s", step.Action)
+	}
+}
+
+// cleaningStepLabel describes a step of a cleaning pattern
+func cleaningStepLabel(step models.PumpCleaningStep) string {
+	switch step.Action {
+	case "soak":
+		return fmt.Sprintf("soaking for %s", time.Duration(step.DurationMs)*time.Millisecond)
+	case "reverse":
+		return "pumping back"
+	default:
+		return "flushing"
+	}
+}
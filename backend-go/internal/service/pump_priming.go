@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// PumpUp primes a pump in the background: it runs forward until the tube
+// holds TubeCapacity ml, which is taken from the filling level
+func (s *PumpService) PumpUp(pumpID int64) error {
+	pump, err := s.repo.FindByID(pumpID)
+	if err != nil {
+		return fmt.Errorf("failed to find pump: %w", err)
+	}
+	if pump == nil {
+		return errors.New("pump not found")
+	}
+
+	return s.startTubeJob(pump, "pumpUp")
+}
+
+// PumpBack empties the tube of a pump in the background. Reversible pumps
+// run backwards and return the liquid to the bottle. Other pumps run
+// forward, so the bottle has to be taken off first and the liquid ends up
+// in the dispensing area.
+func (s *PumpService) PumpBack(pumpID int64) error {
+	pump, err := s.repo.FindByID(pumpID)
+	if err != nil {
+		return fmt.Errorf("failed to find pump: %w", err)
+	}
+	if pump == nil {
+		return errors.New("pump not found")
+	}
+
+	return s.startTubeJob(pump, "pumpBack")
+}
+
+// PumpUpAll primes every pump that has an ingredient and is not pumped up
+// yet, all at the same time. It returns the IDs of the pumps that started.
+func (s *PumpService) PumpUpAll() ([]int64, error) {
+	pumps, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pumps: %w", err)
+	}
+
+	started := []int64{}
+	for i := range pumps {
+		pump := &pumps[i]
+		if pump.IsPumpedUp || pump.CurrentIngredientID == nil {
+			continue
+		}
+		if err := s.startTubeJob(pump, "pumpUp"); err != nil {
+			log.Printf("Not pumping up pump %s: %v", pumpDisplayName(pump), err)
+			continue
+		}
+		started = append(started, pump.ID)
+	}
+	return started, nil
+}
+
+// startTubeJob checks that a pump can move its tube capacity and starts
+// the job in the background
+func (s *PumpService) startTubeJob(pump *models.Pump, job string) error {
+	if pump.TubeCapacity == nil || *pump.TubeCapacity <= 0 {
+		return fmt.Errorf("pump %s has no tube capacity", pumpDisplayName(pump))
+	}
+	if !s.runtime.Available() {
+		return errGPIONotAvailable
+	}
+	if s.runtime.IsStopped() {
+		return errPumpsStopped
+	}
+	if s.runtime.IsRunning(pump.ID) {
+		return fmt.Errorf("pump %s is already running", pumpDisplayName(pump))
+	}
+
+	amountMl := int(math.Round(*pump.TubeCapacity))
+	expected, err := pumpRunDuration(pump, pump.CurrentIngredient, amountMl)
+	if err != nil {
+		return err
+	}
+
+	go s.runTubeJob(pump, job, amountMl, expected)
+	return nil
+}
+
+// runTubeJob moves the tube capacity of a pump and updates the pump once it
+// is done. The runtime publishes its progress on the pump's running state
+// topic.
+func (s *PumpService) runTubeJob(pump *models.Pump, job string, amountMl int, expected time.Duration) {
+	ctx := withPumpJob(context.Background(), job)
+	backward := job == "pumpBack" && pump.IsReversible()
+
+	started := time.Now()
+	var err error
+	if backward {
+		err = s.runtime.Reverse(ctx, pump, pump.CurrentIngredient, amountMl)
+	} else {
+		err = s.runtime.Dispense(ctx, pump, pump.CurrentIngredient, amountMl)
+	}
+
+	movedMl := amountMl
+	if err != nil {
+		log.Printf("Pump %s stopped during %s: %v", pumpDisplayName(pump), job, err)
+		movedMl = 0
+		if expected > 0 {
+			movedMl = int(float64(amountMl) * min(float64(time.Since(started))/float64(expected), 1))
+		}
+	}
+
+	if err := s.finishTubeJob(pump, job, backward, movedMl, err == nil); err != nil {
+		log.Printf("Failed to update pump %s: %v", pumpDisplayName(pump), err)
+	}
+	s.publishLayout()
+}
+
+// finishTubeJob accounts for the liquid moved by a tube job. Liquid pumped
+// back into the bottle of a pumped up pump is added to its filling level. A
+// pump counts as pumped up only after a complete run.
+func (s *PumpService) finishTubeJob(pump *models.Pump, job string, backward bool, movedMl int, complete bool) error {
+	if job == "pumpUp" && movedMl > 0 {
+		if err := s.repo.ReduceFillingLevel(pump.ID, movedMl); err != nil {
+			return err
+		}
+	}
+	if backward && pump.IsPumpedUp && movedMl > 0 {
+		if err := s.repo.AddFillingLevel(pump.ID, movedMl); err != nil {
+			return err
+		}
+	}
+	if !complete {
+		return nil
+	}
+	return s.repo.UpdateFields(pump.ID, map[string]interface{}{
+		"is_pumped_up": job == "pumpUp",
+	})
+}
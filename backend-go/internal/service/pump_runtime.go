@@ -0,0 +1,804 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// errPumpsStopped is returned while the pumps are emergency stopped
+var errPumpsStopped = errors.New("pumps are stopped, start them again to continue")
+
+// errPumpWatchdog is returned when the watchdog switched off a pump that
+// ran for longer than the maximum run time
+var errPumpWatchdog = errors.New("pump ran for longer than the maximum run time and was switched off")
+
+// pinTestJob labels the runs of bare pins tested by hand. They are tracked
+// like pump runs, but neither published nor counted as wear.
+const pinTestJob = "pinTest"
+
+// runningStateInterval is how often every running pump publishes its state
+const runningStateInterval = 250 * time.Millisecond
+
+// pumpJobKey is the context key for the job a pump runs for
+type pumpJobKey struct{}
+
+// withPumpJob labels the pump runs started with ctx, for example "pumpUp",
+// so clients can tell what a running pump is doing
+func withPumpJob(ctx context.Context, job string) context.Context {
+	return context.WithValue(ctx, pumpJobKey{}, job)
+}
+
+// pumpJob returns the job label of ctx, or fallback if it has none
+func pumpJob(ctx context.Context, fallback string) string {
+	if job, ok := ctx.Value(pumpJobKey{}).(string); ok {
+		return job
+	}
+	return fallback
+}
+
+// pumpRun describes a pump run for the running state telemetry
+type pumpRun struct {
+	job       string
+	direction string
+	amountMl  float64       // 0 if unknown
+	expected  time.Duration // 0 for runs without an end
+	mlPerSec  float64       // flow used when there is no expected duration
+}
+
+// pumpStop records when and in which direction a pump last stopped
+type pumpStop struct {
+	direction string
+	at        time.Time
+}
+
+// runningPump is a pump motor that is currently driven
+type runningPump struct {
+	pump    *models.Pump
+	cancel  context.CancelFunc
+	run     pumpRun
+	started time.Time
+}
+
+// state returns the running state of the pump at the current time
+func (p *runningPump) state() models.PumpRunningState {
+	elapsed := time.Since(p.started)
+	state := models.PumpRunningState{
+		PumpID:     p.pump.ID,
+		Job:        p.run.job,
+		Direction:  p.run.direction,
+		AmountInMl: p.run.amountMl,
+		Running:    true,
+	}
+
+	if p.run.expected > 0 {
+		fraction := min(float64(elapsed)/float64(p.run.expected), 0.99)
+		remaining := max(p.run.expected-elapsed, 0).Milliseconds()
+		state.PercentDone = int(fraction * 100)
+		state.DispensedInMl = roundMl(p.run.amountMl * fraction)
+		state.TimeRemainingMs = &remaining
+	} else {
+		state.DispensedInMl = roundMl(p.run.mlPerSec * elapsed.Seconds())
+	}
+	return state
+}
+
+// finalState returns the running state after the run ended with err
+func (p *runningPump) finalState(err error) models.PumpRunningState {
+	state := p.state()
+	state.Running = false
+	switch {
+	case err == nil:
+		var remaining int64
+		state.PercentDone = 100
+		state.TimeRemainingMs = &remaining
+		if p.run.expected > 0 {
+			state.DispensedInMl = p.run.amountMl
+		}
+	case errors.Is(err, context.Canceled):
+		state.Error = "stopped"
+	default:
+		state.Error = err.Error()
+	}
+	return state
+}
+
+// PumpRuntime tracks every running pump motor so it can be stopped at any
+// time. After an emergency stop no pump runs until the runtime is re-armed.
+// A watchdog switches off every pump that runs for longer than the maximum
+// run time, even if the goroutine driving it hangs. Reversible pumps wait
+// for the dead time before they change direction.
+type PumpRuntime struct {
+	boards    *GPIOBoards
+	publisher EventPublisher
+	maxRun    time.Duration
+	deadTime  time.Duration
+	running   map[int64]*runningPump
+	lastStops map[int64]pumpStop
+	stopped   bool
+	onStop    []func()
+	onRearm   []func()
+	onRunEnd  []func(pump *models.Pump, usage models.PumpWear)
+	mu        sync.Mutex
+}
+
+// NewPumpRuntime creates a new pump runtime that drives the pumps through
+// the boards their pins belong to. While a pump runs its state is published
+// on its running state topic.
+func NewPumpRuntime(cfg *config.Config, boards *GPIOBoards, publisher EventPublisher) *PumpRuntime {
+	return &PumpRuntime{
+		boards:    boards,
+		publisher: publisher,
+		maxRun:    cfg.Pump.MaxRunTime,
+		deadTime:  cfg.Pump.DirectionDeadTime,
+		running:   make(map[int64]*runningPump),
+		lastStops: make(map[int64]pumpStop),
+	}
+}
+
+// Available reports whether pumps can be driven at all
+func (r *PumpRuntime) Available() bool {
+	return r.boards != nil && r.boards.Available()
+}
+
+// IsStopped reports whether the pumps are emergency stopped
+func (r *PumpRuntime) IsStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stopped
+}
+
+// IsRunning reports whether a pump is currently running
+func (r *PumpRuntime) IsRunning(pumpID int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, running := r.running[pumpID]
+	return running
+}
+
+// OnEmergencyStop registers a function that is called after an emergency stop
+func (r *PumpRuntime) OnEmergencyStop(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onStop = append(r.onStop, fn)
+}
+
+// OnRearm registers a function that is called after the pumps are re-armed
+func (r *PumpRuntime) OnRearm(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onRearm = append(r.onRearm, fn)
+}
+
+// OnRunEnd registers a function that is called with the usage of every pump
+// run once it has ended
+func (r *PumpRuntime) OnRunEnd(fn func(pump *models.Pump, usage models.PumpWear)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onRunEnd = append(r.onRunEnd, fn)
+}
+
+// Dispense runs a pump until amountMl of the ingredient is dispensed or ctx
+// is cancelled
+func (r *PumpRuntime) Dispense(ctx context.Context, pump *models.Pump, ingredient *models.Ingredient, amountMl int) error {
+	ctx = withPumpJob(ctx, pumpJob(ctx, "dispense"))
+
+	switch pump.DType {
+	case "DcPump", "ValvePump":
+		duration, err := pumpRunDuration(pump, ingredient, amountMl)
+		if err != nil {
+			return err
+		}
+		return r.runSwitched(ctx, pump, "forward", duration, float64(amountMl))
+
+	case "StepperPump":
+		if pump.StepsPerCl == nil {
+			return errors.New("pump is not fully configured")
+		}
+		_, err := r.runStepper(ctx, pump, stepperSteps(pump, ingredient, amountMl), float64(amountMl))
+		return err
+
+	default:
+		return fmt.Errorf("unsupported pump type: %s", pump.DType)
+	}
+}
+
+// Reverse runs a reversible pump backwards until amountMl of the ingredient
+// is moved back or ctx is cancelled
+func (r *PumpRuntime) Reverse(ctx context.Context, pump *models.Pump, ingredient *models.Ingredient, amountMl int) error {
+	if !pump.IsReversible() {
+		return fmt.Errorf("pump %s cannot run backwards", pumpDisplayName(pump))
+	}
+	duration, err := pumpRunDuration(pump, ingredient, amountMl)
+	if err != nil {
+		return err
+	}
+	return r.runSwitched(withPumpJob(ctx, pumpJob(ctx, "dispense")), pump, "backward", duration, float64(amountMl))
+}
+
+// RunFor runs a DC pump or opens a valve for a fixed time regardless of its
+// calibration, or until ctx is cancelled
+func (r *PumpRuntime) RunFor(ctx context.Context, pump *models.Pump, duration time.Duration) error {
+	var amountMl float64
+	if pump.TimePerClInMs != nil && *pump.TimePerClInMs > 0 {
+		amountMl = float64(duration.Milliseconds()) / float64(*pump.TimePerClInMs) * 10
+	}
+	if pump.DType == "ValvePump" {
+		if flow, err := newValveFlow(pump); err == nil {
+			amountMl = flow.poured(float64(pump.FillingLevelInMl), duration)
+		}
+	}
+	return r.runSwitched(withPumpJob(ctx, pumpJob(ctx, "run")), pump, "forward", duration, amountMl)
+}
+
+// RunSteps runs a stepper pump for a fixed number of steps regardless of
+// its calibration, or until ctx is cancelled
+func (r *PumpRuntime) RunSteps(ctx context.Context, pump *models.Pump, steps int) error {
+	var amountMl float64
+	if pump.StepsPerCl != nil && *pump.StepsPerCl > 0 {
+		amountMl = float64(steps) / float64(*pump.StepsPerCl) * 10
+	}
+	_, err := r.runStepper(withPumpJob(ctx, pumpJob(ctx, "run")), pump, steps, amountMl)
+	return err
+}
+
+// TestPin switches a bare pin on the local board on for duration, for
+// example to check the wiring of a relay. The pin is stopped by an
+// emergency stop and the watchdog like a pump.
+func (r *PumpRuntime) TestPin(ctx context.Context, pin int, duration time.Duration, activeHigh bool) error {
+	pump := pinTestPump("DcPump", pin)
+	pump.DcPinNr = &pin
+	pump.IsPowerStateHigh = &activeHigh
+	return r.runSwitched(withPumpJob(ctx, pinTestJob), pump, "forward", duration, 0)
+}
+
+// TestStepper runs a stepper motor wired to bare pins on the local board,
+// for example to check its wiring and speed. The motor is stopped by an
+// emergency stop and the watchdog like a pump.
+func (r *PumpRuntime) TestStepper(ctx context.Context, config StepperMotorConfig) (StepperRun, error) {
+	pump := pinTestPump("StepperPump", config.StepPin)
+	pump.StepPinNr = &config.StepPin
+	pump.EnablePinNr = &config.EnablePin
+	if config.MaxStepsPerSecond > 0 {
+		pump.MaxStepsPerSecond = &config.MaxStepsPerSecond
+	}
+	if config.Acceleration > 0 {
+		pump.Acceleration = &config.Acceleration
+	}
+	return r.runStepper(withPumpJob(ctx, pinTestJob), pump, config.Steps, 0)
+}
+
+// pinTestPump returns a pump standing for a bare pin on the local board
+// during a pin test. Its negative ID never clashes with a configured pump.
+func pinTestPump(dtype string, pin int) *models.Pump {
+	name := fmt.Sprintf("pin %d", pin)
+	return &models.Pump{ID: -1 - int64(pin), DType: dtype, Name: &name}
+}
+
+// runSwitched runs a pump that is switched by a single pin, a DC pump in
+// direction or a valve, for duration, which is expected to move amountMl
+func (r *PumpRuntime) runSwitched(ctx context.Context, pump *models.Pump, direction string, duration time.Duration, amountMl float64) (err error) {
+	if pump.DType != "DcPump" && pump.DType != "ValvePump" {
+		return fmt.Errorf("pump %s is not a DC pump or valve", pumpDisplayName(pump))
+	}
+	board, pin := switchPin(pump)
+	if pin == nil {
+		return errors.New("pump is not fully configured")
+	}
+	if !r.Available() {
+		return errGPIONotAvailable
+	}
+
+	if err := r.waitDeadTime(ctx, pump, direction); err != nil {
+		return err
+	}
+
+	runCtx, release, err := r.begin(ctx, pump, pumpRun{
+		job:       pumpJob(ctx, "run"),
+		direction: direction,
+		amountMl:  amountMl,
+		expected:  duration,
+	})
+	if err != nil {
+		return err
+	}
+	defer r.failSafe(pump)
+	defer func() { err = release(err, 0) }()
+
+	gpio, err := r.boards.Service(board)
+	if err != nil {
+		return err
+	}
+	if pump.IsReversible() {
+		if err := r.setDirection(pump, direction); err != nil {
+			return err
+		}
+		defer r.clearDirection(pump)
+	}
+	return gpio.RunDCPumpContext(runCtx, *pin, int(duration.Milliseconds()), isActiveHigh(pump))
+}
+
+// runStepper runs a stepper pump for a number of steps, which are expected
+// to move amountMl
+func (r *PumpRuntime) runStepper(ctx context.Context, pump *models.Pump, steps int, amountMl float64) (run StepperRun, err error) {
+	if pump.DType != "StepperPump" {
+		return run, fmt.Errorf("pump %s is not a stepper pump", pumpDisplayName(pump))
+	}
+	if pump.StepPinNr == nil || pump.EnablePinNr == nil {
+		return run, errors.New("pump is not fully configured")
+	}
+	if !r.Available() {
+		return run, errGPIONotAvailable
+	}
+
+	gpio, config, err := r.stepperConfig(pump, steps)
+	if err != nil {
+		return run, err
+	}
+
+	runCtx, release, err := r.begin(ctx, pump, pumpRun{
+		job:      pumpJob(ctx, "run"),
+		amountMl: amountMl,
+		expected: planStepperMotion(steps, config.MaxStepsPerSecond, config.Acceleration).Duration(),
+	})
+	if err != nil {
+		return run, err
+	}
+	defer r.failSafe(pump)
+	defer func() { err = release(err, run.Steps) }()
+
+	run, err = gpio.RunStepperMotorContext(runCtx, config)
+	log.Printf("Pump %s: %d of %d steps in %s (planned %s)", pumpDisplayName(pump), run.Steps, config.Steps, run.Actual, run.Planned)
+	return run, err
+}
+
+// StartContinuous runs a pump without a target amount until it is stopped,
+// for example to purge the tubes by hand
+func (r *PumpRuntime) StartContinuous(pump *models.Pump) error {
+	if !r.Available() {
+		return errGPIONotAvailable
+	}
+
+	var run func(ctx context.Context) (steps int, err error)
+	var mlPerSec float64
+	switch pump.DType {
+	case "DcPump":
+		if pump.DcPinNr == nil {
+			return errors.New("pump is not fully configured")
+		}
+		gpio, err := r.boards.Service(pump.DcPinBoard)
+		if err != nil {
+			return err
+		}
+		run = func(ctx context.Context) (int, error) {
+			if pump.IsReversible() {
+				if err := r.waitDeadTime(ctx, pump, "forward"); err != nil {
+					return 0, err
+				}
+				if err := r.setDirection(pump, "forward"); err != nil {
+					return 0, err
+				}
+				defer r.clearDirection(pump)
+			}
+			return 0, switchOnUntilStopped(ctx, gpio, *pump.DcPinNr, isActiveHigh(pump))
+		}
+		if pump.TimePerClInMs != nil && *pump.TimePerClInMs > 0 {
+			mlPerSec = 10000 / float64(*pump.TimePerClInMs)
+		}
+	case "ValvePump":
+		if pump.ValvePinNr == nil {
+			return errors.New("pump is not fully configured")
+		}
+		gpio, err := r.boards.Service(pump.ValvePinBoard)
+		if err != nil {
+			return err
+		}
+		run = func(ctx context.Context) (int, error) {
+			return 0, switchOnUntilStopped(ctx, gpio, *pump.ValvePinNr, isActiveHigh(pump))
+		}
+		if flow, err := newValveFlow(pump); err == nil {
+			mlPerSec = flow.rate(float64(pump.FillingLevelInMl))
+		}
+	case "StepperPump":
+		if pump.StepPinNr == nil || pump.EnablePinNr == nil {
+			return errors.New("pump is not fully configured")
+		}
+		gpio, config, err := r.stepperConfig(pump, math.MaxInt)
+		if err != nil {
+			return err
+		}
+		run = func(ctx context.Context) (int, error) {
+			run, err := gpio.RunStepperMotorContext(ctx, config)
+			return run.Steps, err
+		}
+		if pump.StepsPerCl != nil && *pump.StepsPerCl > 0 {
+			mlPerSec = float64(config.MaxStepsPerSecond) / float64(*pump.StepsPerCl) * 10
+		}
+	default:
+		return fmt.Errorf("unsupported pump type: %s", pump.DType)
+	}
+
+	runCtx, release, err := r.begin(context.Background(), pump, pumpRun{
+		job:      "continuous",
+		mlPerSec: mlPerSec,
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer r.failSafe(pump)
+
+		steps, err := run(runCtx)
+		err = release(err, steps)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Pump %s stopped with error: %v", pumpDisplayName(pump), err)
+		}
+	}()
+
+	return nil
+}
+
+// StopPump stops a single running pump. It reports whether the pump was running.
+func (r *PumpRuntime) StopPump(pumpID int64) bool {
+	r.mu.Lock()
+	running, ok := r.running[pumpID]
+	r.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	running.cancel()
+	r.forceInactive(running.pump)
+	return true
+}
+
+// EmergencyStop stops every running pump, drives their pins to the inactive
+// level and refuses to run pumps until Rearm is called
+func (r *PumpRuntime) EmergencyStop() {
+	r.mu.Lock()
+	r.stopped = true
+	pumps := make([]*models.Pump, 0, len(r.running))
+	for _, running := range r.running {
+		running.cancel()
+		pumps = append(pumps, running.pump)
+	}
+	hooks := append([]func(){}, r.onStop...)
+	r.mu.Unlock()
+
+	for _, pump := range pumps {
+		r.forceInactive(pump)
+	}
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// Rearm allows pumps to run again after an emergency stop
+func (r *PumpRuntime) Rearm() {
+	r.mu.Lock()
+	wasStopped := r.stopped
+	r.stopped = false
+	hooks := append([]func(){}, r.onRearm...)
+	r.mu.Unlock()
+
+	if !wasStopped {
+		return
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// begin registers a pump as running, arms the watchdog and starts
+// publishing its state. It returns the context of the run together with a
+// function that unregisters the pump again, publishes how the run ended and
+// reports its usage. The function returns errPumpWatchdog instead of err if
+// the watchdog stopped the run.
+func (r *PumpRuntime) begin(ctx context.Context, pump *models.Pump, run pumpRun) (context.Context, func(err error, steps int) error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return nil, nil, errPumpsStopped
+	}
+	if _, running := r.running[pump.ID]; running {
+		return nil, nil, fmt.Errorf("pump %s is already running", pumpDisplayName(pump))
+	}
+	if run.expected > r.maxRun {
+		return nil, nil, fmt.Errorf("pump %s would run for %s, longer than the maximum run time of %s",
+			pumpDisplayName(pump), run.expected.Round(time.Second), r.maxRun)
+	}
+
+	if run.direction == "" {
+		run.direction = "forward"
+	}
+	runCtx, cancelCause := context.WithCancelCause(ctx)
+	cancel := func() { cancelCause(context.Canceled) }
+	running := &runningPump{pump: pump, cancel: cancel, run: run, started: time.Now()}
+	r.running[pump.ID] = running
+
+	watchdog := time.AfterFunc(r.maxRun, func() {
+		log.Printf("Watchdog: pump %s ran for longer than %s, switching it off", pumpDisplayName(pump), r.maxRun)
+		cancelCause(errPumpWatchdog)
+		r.forceInactive(pump)
+	})
+
+	reported := make(chan struct{})
+	go r.reportRunningState(runCtx, running, reported)
+
+	release := func(err error, steps int) error {
+		watchdog.Stop()
+		if err != nil && errors.Is(context.Cause(runCtx), errPumpWatchdog) {
+			err = errPumpWatchdog
+		}
+		cancel()
+		<-reported
+		r.mu.Lock()
+		delete(r.running, pump.ID)
+		r.lastStops[pump.ID] = pumpStop{direction: run.direction, at: time.Now()}
+		hooks := append([]func(*models.Pump, models.PumpWear){}, r.onRunEnd...)
+		r.mu.Unlock()
+
+		state := running.finalState(err)
+		r.publishRunningState(state)
+		if run.job == pinTestJob {
+			return err
+		}
+
+		usage := models.PumpWear{
+			PumpID:    pump.ID,
+			RunTimeMs: time.Since(running.started).Milliseconds(),
+			Steps:     int64(steps),
+			MovedMl:   state.DispensedInMl,
+			Starts:    1,
+		}
+		for _, hook := range hooks {
+			hook(pump, usage)
+		}
+		return err
+	}
+	return runCtx, release, nil
+}
+
+// reportRunningState publishes the state of a running pump at a steady rate
+// until its run ends
+func (r *PumpRuntime) reportRunningState(ctx context.Context, running *runningPump, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(runningStateInterval)
+	defer ticker.Stop()
+
+	r.publishRunningState(running.state())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.publishRunningState(running.state())
+		}
+	}
+}
+
+// publishRunningState pushes the state of a pump to all clients
+func (r *PumpRuntime) publishRunningState(state models.PumpRunningState) {
+	if r.publisher == nil || state.Job == pinTestJob {
+		return
+	}
+	r.publisher.BroadcastPumpRunningState(state.PumpID, state)
+}
+
+// switchOnUntilStopped switches a DC pump or valve on until ctx is cancelled
+func switchOnUntilStopped(ctx context.Context, gpio *GPIOService, pin int, activeHigh bool) error {
+	if err := gpio.setupInactiveOutput(pin, activeHigh); err != nil {
+		return fmt.Errorf("failed to setup pump pin: %w", err)
+	}
+	if err := gpio.setPinActive(pin, activeHigh, true); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+
+	if err := gpio.setPinActive(pin, activeHigh, false); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// ResetOutputs drives the pins of all pumps to their inactive level, for
+// example on startup and shutdown, so no pump is left running
+func (r *PumpRuntime) ResetOutputs(pumps []models.Pump) {
+	if !r.Available() {
+		return
+	}
+	for i := range pumps {
+		r.forceInactive(&pumps[i])
+	}
+}
+
+// failSafe switches every pump off if the calling goroutine panics while
+// driving pump, and lets the panic continue. Must be deferred.
+func (r *PumpRuntime) failSafe(pump *models.Pump) {
+	if p := recover(); p != nil {
+		log.Printf("Panic while driving pump %s, switching all pumps off: %v", pumpDisplayName(pump), p)
+		r.forceInactive(pump)
+		r.EmergencyStop()
+		panic(p)
+	}
+}
+
+// forceInactive drives the pins of a pump to their inactive level right
+// away, without waiting for the goroutine running the pump to notice.
+// Pins that are not set up yet are set up at their inactive level.
+func (r *PumpRuntime) forceInactive(pump *models.Pump) {
+	var err error
+	switch pump.DType {
+	case "DcPump":
+		if pump.DcPinNr != nil {
+			err = r.driveInactive(pump.DcPinBoard, *pump.DcPinNr, isActiveHigh(pump))
+		}
+		if pump.IsReversible() {
+			if dirErr := r.clearDirectionPins(pump); dirErr != nil {
+				err = dirErr
+			}
+		}
+	case "ValvePump":
+		if pump.ValvePinNr != nil {
+			err = r.driveInactive(pump.ValvePinBoard, *pump.ValvePinNr, isActiveHigh(pump))
+		}
+	case "StepperPump":
+		// The enable pin is active low, the step pin idles low
+		if pump.EnablePinNr != nil {
+			err = r.driveInactive(pump.EnablePinBoard, *pump.EnablePinNr, false)
+		}
+		if pump.StepPinNr != nil {
+			if stepErr := r.driveInactive(pump.StepPinBoard, *pump.StepPinNr, true); stepErr != nil {
+				err = stepErr
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to switch off pump %s: %v", pumpDisplayName(pump), err)
+	}
+}
+
+// driveInactive drives a pin on a board to its inactive level
+func (r *PumpRuntime) driveInactive(board *int64, pin int, activeHigh bool) error {
+	gpio, err := r.boards.Service(board)
+	if err != nil {
+		return err
+	}
+	if err := gpio.setupInactiveOutput(pin, activeHigh); err != nil {
+		return err
+	}
+	return gpio.setPinActive(pin, activeHigh, false)
+}
+
+// waitDeadTime waits until a reversible pump that last ran in the other
+// direction has been off for the dead time, so the H-bridge is never
+// reversed while the motor still turns
+func (r *PumpRuntime) waitDeadTime(ctx context.Context, pump *models.Pump, direction string) error {
+	if !pump.IsReversible() {
+		return nil
+	}
+
+	r.mu.Lock()
+	last, ok := r.lastStops[pump.ID]
+	r.mu.Unlock()
+	if !ok || last.direction == direction {
+		return nil
+	}
+
+	wait := r.deadTime - time.Since(last.at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// setDirection drives the H-bridge direction pins of a reversible pump for
+// direction. Both pins go low before one of them goes high, so the bridge
+// never sees both inputs high.
+func (r *PumpRuntime) setDirection(pump *models.Pump, direction string) error {
+	if err := r.clearDirectionPins(pump); err != nil {
+		return fmt.Errorf("failed to set pump direction: %w", err)
+	}
+
+	board, pin := pump.ForwardPinBoard, *pump.ForwardPinNr
+	if direction == "backward" {
+		board, pin = pump.BackwardPinBoard, *pump.BackwardPinNr
+	}
+	gpio, err := r.boards.Service(board)
+	if err != nil {
+		return err
+	}
+	if err := gpio.SetPinHigh(pin); err != nil {
+		return fmt.Errorf("failed to set pump direction: %w", err)
+	}
+	return nil
+}
+
+// clearDirection drives both direction pins of a reversible pump low after
+// a run and logs if that fails
+func (r *PumpRuntime) clearDirection(pump *models.Pump) {
+	if err := r.clearDirectionPins(pump); err != nil {
+		log.Printf("Failed to clear direction of pump %s: %v", pumpDisplayName(pump), err)
+	}
+}
+
+// clearDirectionPins drives both direction pins of a reversible pump low,
+// which leaves the H-bridge off
+func (r *PumpRuntime) clearDirectionPins(pump *models.Pump) error {
+	if err := r.driveInactive(pump.ForwardPinBoard, *pump.ForwardPinNr, true); err != nil {
+		return err
+	}
+	return r.driveInactive(pump.BackwardPinBoard, *pump.BackwardPinNr, true)
+}
+
+// stepperConfig returns the motor configuration of a stepper pump together
+// with the GPIO service driving its step pin
+func (r *PumpRuntime) stepperConfig(pump *models.Pump, steps int) (*GPIOService, StepperMotorConfig, error) {
+	stepGPIO, err := r.boards.Service(pump.StepPinBoard)
+	if err != nil {
+		return nil, StepperMotorConfig{}, err
+	}
+	enableGPIO, err := r.boards.Service(pump.EnablePinBoard)
+	if err != nil {
+		return nil, StepperMotorConfig{}, err
+	}
+
+	config := StepperMotorConfig{
+		StepPin:           *pump.StepPinNr,
+		EnablePin:         *pump.EnablePinNr,
+		EnableGPIO:        enableGPIO,
+		Steps:             steps,
+		MaxStepsPerSecond: defaultMaxStepsPerSecond,
+	}
+	if pump.MaxStepsPerSecond != nil {
+		config.MaxStepsPerSecond = *pump.MaxStepsPerSecond
+	}
+	if pump.Acceleration != nil {
+		config.Acceleration = *pump.Acceleration
+	}
+	return stepGPIO, config, nil
+}
+
+// switchPin returns the pin that switches a DC pump or valve
+func switchPin(pump *models.Pump) (*int64, *int) {
+	if pump.DType == "ValvePump" {
+		return pump.ValvePinBoard, pump.ValvePinNr
+	}
+	return pump.DcPinBoard, pump.DcPinNr
+}
+
+// isActiveHigh reports whether a DC pump or valve is switched on with a high
+// level
+func isActiveHigh(pump *models.Pump) bool {
+	return pump.IsPowerStateHigh == nil || *pump.IsPowerStateHigh
+}
+
+// roundMl rounds an amount to a tenth of a millilitre
+func roundMl(ml float64) float64 {
+	return math.Round(ml*10) / 10
+}
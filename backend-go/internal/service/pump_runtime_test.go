@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// timingTolerance is how much later than planned a simulated pin may switch
+const timingTolerance = 50 * time.Millisecond
+
+// newSimulatedRuntime returns a pump runtime driving the local board with
+// the simulated pin driver
+func newSimulatedRuntime() (*PumpRuntime, *SimulatedPinDriver) {
+	driver := NewSimulatedPinDriver()
+	boards := NewGPIOBoards(NewGPIOService(driver), nil, func() models.I2CSettings { return models.I2CSettings{} }, nil)
+	cfg := &config.Config{Pump: config.PumpConfig{MaxRunTime: time.Minute}}
+	return NewPumpRuntime(cfg, boards, nil), driver
+}
+
+func TestPumpRuntimeRunFor(t *testing.T) {
+	activeLow := false
+	tests := []struct {
+		name     string
+		pump     models.Pump
+		pin      int
+		duration time.Duration
+		want     []int // levels the pin switches to
+	}{
+		{
+			name:     "DC pump active high",
+			pump:     models.Pump{ID: 1, DType: "DcPump", DcPinNr: intPtr(5), TimePerClInMs: intPtr(1000)},
+			pin:      5,
+			duration: 100 * time.Millisecond,
+			want:     []int{1, 0},
+		},
+		{
+			name:     "DC pump active low",
+			pump:     models.Pump{ID: 2, DType: "DcPump", DcPinNr: intPtr(6), TimePerClInMs: intPtr(1000), IsPowerStateHigh: &activeLow},
+			pin:      6,
+			duration: 150 * time.Millisecond,
+			want:     []int{0, 1},
+		},
+		{
+			name:     "valve pump",
+			pump:     models.Pump{ID: 3, DType: "ValvePump", ValvePinNr: intPtr(7), FillingLevelInMl: 500},
+			pin:      7,
+			duration: 80 * time.Millisecond,
+			want:     []int{1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime, driver := newSimulatedRuntime()
+
+			start := time.Now()
+			if err := runtime.RunFor(context.Background(), &tt.pump, tt.duration); err != nil {
+				t.Fatalf("RunFor: %v", err)
+			}
+
+			transitions := driver.Transitions()
+			if len(transitions) != len(tt.want) {
+				t.Fatalf("transitions = %+v, want levels %v", transitions, tt.want)
+			}
+			for i, transition := range transitions {
+				if transition.Pin != tt.pin || transition.Value != tt.want[i] {
+					t.Errorf("transition %d = pin %d to %d, want pin %d to %d", i, transition.Pin, transition.Value, tt.pin, tt.want[i])
+				}
+			}
+			if delay := transitions[0].At.Sub(start); delay > timingTolerance {
+				t.Errorf("pump switched on after %s, want at most %s", delay, timingTolerance)
+			}
+			if ran := transitions[1].At.Sub(transitions[0].At); ran < tt.duration || ran > tt.duration+timingTolerance {
+				t.Errorf("pump ran %s, want %s", ran, tt.duration)
+			}
+			if runtime.IsRunning(tt.pump.ID) {
+				t.Error("pump still running after RunFor returned")
+			}
+		})
+	}
+}
+
+func TestPumpRuntimeRunForCancelled(t *testing.T) {
+	runtime, driver := newSimulatedRuntime()
+	pump := models.Pump{ID: 1, DType: "DcPump", DcPinNr: intPtr(5), TimePerClInMs: intPtr(1000)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := runtime.RunFor(ctx, &pump, time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunFor = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	transitions := driver.Transitions()
+	if len(transitions) != 2 || transitions[0].Value != 1 || transitions[1].Value != 0 {
+		t.Fatalf("transitions = %+v, want the pin switched on and off", transitions)
+	}
+	if ran := transitions[1].At.Sub(transitions[0].At); ran > 50*time.Millisecond+timingTolerance {
+		t.Errorf("pump ran %s after its context ended", ran)
+	}
+}
+
+func TestPumpRuntimeTestPinEmergencyStop(t *testing.T) {
+	runtime, driver := newSimulatedRuntime()
+
+	done := make(chan error, 1)
+	go func() { done <- runtime.TestPin(context.Background(), 9, time.Second, true) }()
+	time.Sleep(50 * time.Millisecond)
+	runtime.EmergencyStop()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("TestPin = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TestPin still running after the emergency stop")
+	}
+	if value, err := driver.Value(9); err != nil || value != 0 {
+		t.Errorf("pin 9 = %d, %v after the emergency stop, want 0", value, err)
+	}
+
+	if err := runtime.TestPin(context.Background(), 9, 10*time.Millisecond, true); !errors.Is(err, errPumpsStopped) {
+		t.Errorf("TestPin while stopped = %v, want %v", err, errPumpsStopped)
+	}
+	runtime.Rearm()
+	if err := runtime.TestPin(context.Background(), 9, 10*time.Millisecond, true); err != nil {
+		t.Errorf("TestPin after re-arming = %v", err)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
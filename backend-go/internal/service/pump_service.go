@@ -3,7 +3,10 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/config"
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
 	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/repository"
 )
@@ -12,24 +15,76 @@ import (
 type PumpService struct {
 	repo           *repository.PumpRepository
 	ingredientRepo *repository.IngredientRepository
+	gpioRepo       *repository.GpioRepository
+	runtime        *PumpRuntime
+	publisher      EventPublisher
+	wearLimits     models.TubeWearLimits
 }
 
-// NewPumpService creates a new pump service
-func NewPumpService(repo *repository.PumpRepository, ingredientRepo *repository.IngredientRepository) *PumpService {
-	return &PumpService{
+// NewPumpService creates a new pump service. It adds up the usage of every
+// pump run in the wear counters of the pump.
+func NewPumpService(cfg *config.Config, repo *repository.PumpRepository, ingredientRepo *repository.IngredientRepository, gpioRepo *repository.GpioRepository, runtime *PumpRuntime, publisher EventPublisher) *PumpService {
+	s := &PumpService{
 		repo:           repo,
 		ingredientRepo: ingredientRepo,
+		gpioRepo:       gpioRepo,
+		runtime:        runtime,
+		publisher:      publisher,
+		wearLimits:     tubeWearLimits(cfg),
 	}
+
+	runtime.OnRunEnd(s.recordWear)
+
+	return s
 }
 
 // GetAll returns all pumps
 func (s *PumpService) GetAll() ([]models.Pump, error) {
-	return s.repo.FindAll()
+	pumps, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	flagTubeWear(pumps, s.wearLimits)
+	return pumps, nil
 }
 
 // GetByID returns a pump by ID
 func (s *PumpService) GetByID(id int64) (*models.Pump, error) {
-	return s.repo.FindByID(id)
+	pump, err := s.repo.FindByID(id)
+	if err != nil || pump == nil {
+		return pump, err
+	}
+	pump.TubeReplacementDue = pump.Wear.TubeReplacementDue(s.wearLimits)
+	return pump, nil
+}
+
+// ResetOutputs switches off every configured pump by driving its pins to
+// their inactive level
+func (s *PumpService) ResetOutputs() {
+	pumps, err := s.repo.FindAll()
+	if err != nil {
+		log.Printf("Failed to get pumps: %v", err)
+		return
+	}
+	s.runtime.ResetOutputs(pumps)
+}
+
+// ResetWear clears the wear counters of a pump after its tube was replaced
+func (s *PumpService) ResetWear(id int64) error {
+	existing, err := s.repo.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to find pump: %w", err)
+	}
+	if existing == nil {
+		return errors.New("pump not found")
+	}
+
+	if err := s.repo.ResetWear(id, time.Now()); err != nil {
+		return fmt.Errorf("failed to reset wear: %w", err)
+	}
+
+	s.publishLayout()
+	return nil
 }
 
 // Create creates a new pump
@@ -38,7 +93,12 @@ func (s *PumpService) Create(pump *models.Pump) error {
 		return err
 	}
 
-	return s.repo.Create(pump)
+	if err := s.repo.Create(pump, assignedPins(pumpPinAssignments(pump))); err != nil {
+		return err
+	}
+
+	s.publishLayout()
+	return nil
 }
 
 // Update updates an existing pump
@@ -55,7 +115,12 @@ func (s *PumpService) Update(pump *models.Pump) error {
 		return errors.New("pump not found")
 	}
 
-	return s.repo.Update(pump)
+	if err := s.repo.Update(pump, assignedPins(pumpPinAssignments(pump))); err != nil {
+		return err
+	}
+
+	s.publishLayout()
+	return nil
 }
 
 // UpdateFields updates specific fields of a pump
@@ -68,20 +133,21 @@ func (s *PumpService) UpdateFields(id int64, fields map[string]interface{}) erro
 		return errors.New("pump not found")
 	}
 
-	// Validate ingredient if being updated
-	if ingredientID, ok := fields["current_ingredient_id"]; ok && ingredientID != nil {
-		if id, ok := ingredientID.(int64); ok && id > 0 {
-			ingredient, err := s.ingredientRepo.FindByID(id)
-			if err != nil {
-				return fmt.Errorf("failed to find ingredient: %w", err)
-			}
-			if ingredient == nil {
-				return errors.New("ingredient not found")
-			}
-		}
+	merged := *existing
+	merged.CurrentIngredient = nil
+	if err := s.repo.ApplyFields(&merged, fields); err != nil {
+		return err
+	}
+	if err := s.validatePump(&merged); err != nil {
+		return err
 	}
 
-	return s.repo.UpdateFields(id, fields)
+	if err := s.repo.UpdateFieldsWithPins(id, fields, assignedPins(pumpPinAssignments(&merged))); err != nil {
+		return err
+	}
+
+	s.publishLayout()
+	return nil
 }
 
 // Delete deletes a pump by ID
@@ -94,7 +160,12 @@ func (s *PumpService) Delete(id int64) error {
 		return errors.New("pump not found")
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	s.publishLayout()
+	return nil
 }
 
 // SetIngredient sets the current ingredient for a pump
@@ -120,7 +191,12 @@ func (s *PumpService) SetIngredient(pumpID int64, ingredientID *int64) error {
 	fields := map[string]interface{}{
 		"current_ingredient_id": ingredientID,
 	}
-	return s.repo.UpdateFields(pumpID, fields)
+	if err := s.repo.UpdateFields(pumpID, fields); err != nil {
+		return err
+	}
+
+	s.publishLayout()
+	return nil
 }
 
 // SetFillingLevel sets the filling level of a pump
@@ -140,12 +216,23 @@ func (s *PumpService) SetFillingLevel(pumpID int64, level int) error {
 	fields := map[string]interface{}{
 		"filling_level_in_ml": level,
 	}
-	return s.repo.UpdateFields(pumpID, fields)
+	if err := s.repo.UpdateFields(pumpID, fields); err != nil {
+		return err
+	}
+
+	s.publishLayout()
+	return nil
 }
 
-// SetPumpedUp sets the pumped up status
-func (s *PumpService) SetPumpedUp(pumpID int64, isPumpedUp bool) error {
-	pump, err := s.repo.FindByID(pumpID)
+// Start runs a pump continuously until it is stopped. Without a pump ID
+// the pumps are re-armed after an emergency stop.
+func (s *PumpService) Start(pumpID *int64) error {
+	if pumpID == nil {
+		s.runtime.Rearm()
+		return nil
+	}
+
+	pump, err := s.repo.FindByID(*pumpID)
 	if err != nil {
 		return fmt.Errorf("failed to find pump: %w", err)
 	}
@@ -153,10 +240,28 @@ func (s *PumpService) SetPumpedUp(pumpID int64, isPumpedUp bool) error {
 		return errors.New("pump not found")
 	}
 
-	fields := map[string]interface{}{
-		"is_pumped_up": isPumpedUp,
+	return s.runtime.StartContinuous(pump)
+}
+
+// Stop stops a single pump. Without a pump ID all pumps are emergency
+// stopped, the current cocktail is cancelled and no pump runs until the
+// pumps are started again.
+func (s *PumpService) Stop(pumpID *int64) error {
+	if pumpID == nil {
+		s.runtime.EmergencyStop()
+		return nil
+	}
+
+	pump, err := s.repo.FindByID(*pumpID)
+	if err != nil {
+		return fmt.Errorf("failed to find pump: %w", err)
 	}
-	return s.repo.UpdateFields(pumpID, fields)
+	if pump == nil {
+		return errors.New("pump not found")
+	}
+
+	s.runtime.StopPump(pump.ID)
+	return nil
 }
 
 // validatePump validates pump data
@@ -169,6 +274,7 @@ func (s *PumpService) validatePump(pump *models.Pump) error {
 	validTypes := map[string]bool{
 		"DcPump":      true,
 		"StepperPump": true,
+		"ValvePump":   true,
 	}
 	if !validTypes[pump.DType] {
 		return fmt.Errorf("invalid pump type: %s", pump.DType)
@@ -183,6 +289,9 @@ func (s *PumpService) validatePump(pump *models.Pump) error {
 			return errors.New("DC pump requires valid timePerClInMs (>= 1)")
 		}
 	}
+	if err := validateDirectionPins(pump); err != nil {
+		return err
+	}
 
 	// Validate Stepper pump requirements
 	if pump.DType == "StepperPump" {
@@ -203,11 +312,31 @@ func (s *PumpService) validatePump(pump *models.Pump) error {
 		}
 	}
 
+	// Validate valve pump requirements
+	if pump.DType == "ValvePump" {
+		if pump.ValvePinBoard == nil || pump.ValvePinNr == nil {
+			return errors.New("Valve pump requires valvePinBoard and valvePinNr")
+		}
+		if pump.ValveFlowMlPerSec == nil || *pump.ValveFlowMlPerSec <= 0 {
+			return errors.New("Valve pump requires valid valveFlowMlPerSec (> 0)")
+		}
+		if pump.ValveReferenceLevelInMl == nil || *pump.ValveReferenceLevelInMl < 1 {
+			return errors.New("Valve pump requires valid valveReferenceLevelInMl (>= 1)")
+		}
+		if pump.ValveHeadOffsetInMl != nil && *pump.ValveHeadOffsetInMl < 0 {
+			return errors.New("valve head offset cannot be negative")
+		}
+	}
+
 	// Validate filling level
 	if pump.FillingLevelInMl < 0 {
 		return errors.New("filling level cannot be negative")
 	}
 
+	if pump.LowLevelThresholdInMl != nil && *pump.LowLevelThresholdInMl < 0 {
+		return errors.New("low level threshold cannot be negative")
+	}
+
 	// Validate tube capacity
 	if pump.TubeCapacity != nil && *pump.TubeCapacity < 0 {
 		return errors.New("tube capacity cannot be negative")
@@ -224,5 +353,107 @@ func (s *PumpService) validatePump(pump *models.Pump) error {
 		}
 	}
 
+	return validatePinAssignments(s.gpioRepo, pumpPinAssignments(pump))
+}
+
+// validateDirectionPins checks that a pump has both H-bridge direction pins
+// or none, and only if it is a DC pump
+func validateDirectionPins(pump *models.Pump) error {
+	if pump.ForwardPinBoard == nil && pump.ForwardPinNr == nil &&
+		pump.BackwardPinBoard == nil && pump.BackwardPinNr == nil {
+		return nil
+	}
+	if pump.DType != "DcPump" {
+		return errors.New("only DC pumps can have direction pins")
+	}
+	if !pump.IsReversible() {
+		return errors.New("reversible DC pump requires forwardPinBoard, forwardPinNr, backwardPinBoard and backwardPinNr")
+	}
 	return nil
 }
+
+// pumpPinAssignments returns the pins the pump is configured to use
+func pumpPinAssignments(pump *models.Pump) []models.PinUsage {
+	var assignments []models.PinUsage
+	add := func(board *int64, pinNr *int, function string) {
+		if board == nil || pinNr == nil {
+			return
+		}
+		assignments = append(assignments, models.PinUsage{
+			Board:    *board,
+			PinNr:    *pinNr,
+			Type:     "pump",
+			ID:       pump.ID,
+			Name:     pumpDisplayName(pump),
+			Function: function,
+		})
+	}
+
+	switch pump.DType {
+	case "DcPump":
+		add(pump.DcPinBoard, pump.DcPinNr, "dcPin")
+		add(pump.ForwardPinBoard, pump.ForwardPinNr, "forwardPin")
+		add(pump.BackwardPinBoard, pump.BackwardPinNr, "backwardPin")
+	case "StepperPump":
+		add(pump.StepPinBoard, pump.StepPinNr, "stepPin")
+		add(pump.EnablePinBoard, pump.EnablePinNr, "enablePin")
+	case "ValvePump":
+		add(pump.ValvePinBoard, pump.ValvePinNr, "valvePin")
+	}
+	return assignments
+}
+
+// publishLayout pushes the current pump layout to all clients. Pumps decide
+// which recipes can be made, so cached recipe lists are invalidated as well.
+func (s *PumpService) publishLayout() {
+	if s.publisher == nil {
+		return
+	}
+
+	pumps, err := s.repo.FindAll()
+	if err != nil {
+		log.Printf("Failed to get pumps: %v", err)
+		return
+	}
+
+	flagTubeWear(pumps, s.wearLimits)
+	s.publisher.BroadcastPumpLayout(pumps)
+	s.publisher.InvalidateRecipeScrollCaches()
+}
+
+// recordWear adds the usage of a pump run to the wear counters of the pump.
+// The layout is published when the tube becomes due for replacement.
+func (s *PumpService) recordWear(pump *models.Pump, usage models.PumpWear) {
+	wear, err := s.repo.AddWear(usage)
+	if err != nil {
+		log.Printf("Failed to record wear of pump %s: %v", pumpDisplayName(pump), err)
+		return
+	}
+
+	before := *wear
+	before.RunTimeMs -= usage.RunTimeMs
+	before.Steps -= usage.Steps
+	before.MovedMl -= usage.MovedMl
+	before.Starts -= usage.Starts
+	if wear.TubeReplacementDue(s.wearLimits) && !before.TubeReplacementDue(s.wearLimits) {
+		log.Printf("Warning: the tube of pump %s is due for replacement (%.0f ml moved)", pumpDisplayName(pump), wear.MovedMl)
+		s.publishLayout()
+	}
+}
+
+// tubeWearLimits returns the configured tube maintenance thresholds
+func tubeWearLimits(cfg *config.Config) models.TubeWearLimits {
+	return models.TubeWearLimits{
+		MovedMl:   float64(cfg.Pump.TubeMaxMl),
+		RunTimeMs: cfg.Pump.TubeMaxRunTime.Milliseconds(),
+		Steps:     int64(cfg.Pump.TubeMaxSteps),
+		Starts:    int64(cfg.Pump.TubeMaxStarts),
+	}
+}
+
+// flagTubeWear sets TubeReplacementDue on every pump that reached a limit
+func flagTubeWear(pumps []models.Pump, limits models.TubeWearLimits) {
+	for i := range pumps {
+		pumps[i].TubeReplacementDue = pumps[i].Wear.TubeReplacementDue(limits)
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// stepProgressInterval is how often per pump progress is reported while a
+// production step is running
+const stepProgressInterval = 250 * time.Millisecond
+
+// pumpRunFunc dispenses amountMl of an ingredient through a pump
+type pumpRunFunc func(ctx context.Context, pump *models.Pump, ingredient *models.Ingredient, amountMl int) error
+
+// stepExecutor runs all pumps of a production step at the same time
+type stepExecutor struct {
+	run        pumpRunFunc
+	onProgress func(pumps []models.PumpProgress)
+}
+
+// pumpStepError is returned by the step executor when a pump fails
+type pumpStepError struct {
+	Pump *models.Pump
+	Err  error
+}
+
+func (e *pumpStepError) Error() string {
+	return "pump " + pumpDisplayName(e.Pump) + " failed: " + e.Err.Error()
+}
+
+func (e *pumpStepError) Unwrap() error {
+	return e.Err
+}
+
+// execute starts every pump of the step in its own goroutine and waits until
+// all of them are done. If one pump fails or ctx is cancelled, all other
+// pumps of the step are stopped as well.
+func (e *stepExecutor) execute(ctx context.Context, items []plannedIngredient) error {
+	stepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	progress := make([]models.PumpProgress, len(items))
+	expected := make([]time.Duration, len(items))
+	started := make([]time.Time, len(items))
+
+	for i, item := range items {
+		progress[i] = models.PumpProgress{
+			PumpID:     item.Pump.ID,
+			PumpName:   pumpDisplayName(item.Pump),
+			AmountInMl: item.AmountInMl,
+			Status:     "running",
+		}
+		if item.Ingredient != nil {
+			progress[i].IngredientName = item.Ingredient.Name
+		}
+		expected[i], _ = pumpRunDuration(item.Pump, item.Ingredient, item.AmountInMl)
+		started[i] = time.Now()
+
+		wg.Add(1)
+		go func(i int, item plannedIngredient) {
+			defer wg.Done()
+
+			err := e.run(stepCtx, item.Pump, item.Ingredient, item.AmountInMl)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				progress[i].Status = "error"
+				if firstErr == nil {
+					firstErr = &pumpStepError{Pump: item.Pump, Err: err}
+					cancel()
+				}
+				return
+			}
+			progress[i].Status = "done"
+			progress[i].PercentComplete = 100
+		}(i, item)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(stepProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			e.report(&mu, progress)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return firstErr
+
+		case <-ticker.C:
+			mu.Lock()
+			for i := range progress {
+				if progress[i].Status != "running" || expected[i] <= 0 {
+					continue
+				}
+				percent := int(time.Since(started[i]) * 100 / expected[i])
+				if percent > 99 {
+					percent = 99
+				}
+				progress[i].PercentComplete = percent
+			}
+			mu.Unlock()
+			e.report(&mu, progress)
+		}
+	}
+}
+
+// report passes a copy of the current pump progress to the progress callback
+func (e *stepExecutor) report(mu *sync.Mutex, progress []models.PumpProgress) {
+	if e.onProgress == nil {
+		return
+	}
+
+	mu.Lock()
+	snapshot := make([]models.PumpProgress, len(progress))
+	copy(snapshot, progress)
+	mu.Unlock()
+
+	e.onProgress(snapshot)
+}
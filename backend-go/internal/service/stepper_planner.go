@@ -0,0 +1,72 @@
+package service
+
+import (
+	"math"
+	"time"
+)
+
+// stepperMotion is the speed profile of a stepper motor run. The motor
+// accelerates with a constant acceleration up to the cruise speed, keeps it
+// and decelerates symmetrically. If the run is too short to reach the
+// maximum speed the profile is triangular and the cruise phase is empty.
+type stepperMotion struct {
+	steps        int
+	acceleration float64 // steps/s², 0 means the motor starts at full speed
+	cruiseSpeed  float64 // steps/s
+	rampSteps    float64 // steps needed to accelerate to the cruise speed
+	rampTime     float64 // seconds needed to accelerate to the cruise speed
+}
+
+// planStepperMotion computes the speed profile for a number of steps
+func planStepperMotion(steps int, maxStepsPerSecond int, acceleration int) stepperMotion {
+	if maxStepsPerSecond <= 0 {
+		maxStepsPerSecond = defaultMaxStepsPerSecond
+	}
+
+	motion := stepperMotion{
+		steps:       steps,
+		cruiseSpeed: float64(maxStepsPerSecond),
+	}
+	if acceleration <= 0 {
+		return motion
+	}
+
+	motion.acceleration = float64(acceleration)
+	motion.rampSteps = motion.cruiseSpeed * motion.cruiseSpeed / (2 * motion.acceleration)
+	if 2*motion.rampSteps > float64(steps) {
+		// Triangular profile: turn around halfway at the speed reached there
+		motion.rampSteps = float64(steps) / 2
+		motion.cruiseSpeed = math.Sqrt(motion.acceleration * float64(steps))
+	}
+	motion.rampTime = motion.cruiseSpeed / motion.acceleration
+	return motion
+}
+
+// stepTime returns when step k (1..steps) is due, relative to the start
+func (m stepperMotion) stepTime(k int) time.Duration {
+	position := float64(k)
+	var seconds float64
+	switch {
+	case m.acceleration == 0:
+		seconds = position / m.cruiseSpeed
+	case position <= m.rampSteps:
+		seconds = math.Sqrt(2 * position / m.acceleration)
+	case position < float64(m.steps)-m.rampSteps:
+		seconds = m.rampTime + (position-m.rampSteps)/m.cruiseSpeed
+	default:
+		remaining := float64(m.steps) - position
+		seconds = m.totalSeconds() - math.Sqrt(2*remaining/m.acceleration)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Duration returns how long the whole run takes
+func (m stepperMotion) Duration() time.Duration {
+	return time.Duration(m.totalSeconds() * float64(time.Second))
+}
+
+// totalSeconds returns the length of the run in seconds
+func (m stepperMotion) totalSeconds() float64 {
+	cruiseSteps := float64(m.steps) - 2*m.rampSteps
+	return 2*m.rampTime + cruiseSteps/m.cruiseSpeed
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanStepperMotion(t *testing.T) {
+	tests := []struct {
+		name              string
+		steps             int
+		maxStepsPerSecond int
+		acceleration      int
+		want              time.Duration
+		cruiseSpeed       float64
+		// Expected due time of single steps
+		stepTimes map[int]time.Duration
+	}{
+		{
+			name:              "full speed from the start",
+			steps:             1000,
+			maxStepsPerSecond: 1000,
+			want:              time.Second,
+			cruiseSpeed:       1000,
+			stepTimes:         map[int]time.Duration{1: time.Millisecond, 500: 500 * time.Millisecond},
+		},
+		{
+			name:        "default speed",
+			steps:       500,
+			want:        500 * time.Millisecond,
+			cruiseSpeed: defaultMaxStepsPerSecond,
+		},
+		{
+			name:              "trapezoid",
+			steps:             1000,
+			maxStepsPerSecond: 1000,
+			acceleration:      10000,
+			want:              1100 * time.Millisecond,
+			cruiseSpeed:       1000,
+			stepTimes: map[int]time.Duration{
+				2:   20 * time.Millisecond,
+				50:  100 * time.Millisecond,
+				500: 550 * time.Millisecond,
+				950: 1000 * time.Millisecond,
+				998: 1080 * time.Millisecond,
+			},
+		},
+		{
+			name:              "ramps meeting exactly at full speed",
+			steps:             100,
+			maxStepsPerSecond: 1000,
+			acceleration:      10000,
+			want:              200 * time.Millisecond,
+			cruiseSpeed:       1000,
+			stepTimes:         map[int]time.Duration{50: 100 * time.Millisecond},
+		},
+		{
+			name:              "triangle when full speed is out of reach",
+			steps:             100,
+			maxStepsPerSecond: 1000,
+			acceleration:      2500,
+			want:              400 * time.Millisecond,
+			cruiseSpeed:       500,
+			stepTimes:         map[int]time.Duration{50: 200 * time.Millisecond, 98: 360 * time.Millisecond},
+		},
+		{
+			name:              "single step",
+			steps:             1,
+			maxStepsPerSecond: 1000,
+			acceleration:      10000,
+			want:              20 * time.Millisecond,
+			cruiseSpeed:       100,
+		},
+	}
+
+	near := func(a, b time.Duration) bool {
+		diff := a - b
+		return diff > -10*time.Microsecond && diff < 10*time.Microsecond
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			motion := planStepperMotion(tt.steps, tt.maxStepsPerSecond, tt.acceleration)
+
+			if got := motion.Duration(); !near(got, tt.want) {
+				t.Errorf("Duration = %s, want %s", got, tt.want)
+			}
+			if diff := motion.cruiseSpeed - tt.cruiseSpeed; diff < -1e-6 || diff > 1e-6 {
+				t.Errorf("cruise speed = %f, want %f", motion.cruiseSpeed, tt.cruiseSpeed)
+			}
+			for k, want := range tt.stepTimes {
+				if got := motion.stepTime(k); !near(got, want) {
+					t.Errorf("step %d due at %s, want %s", k, got, want)
+				}
+			}
+
+			// Steps are due one after the other and the last one ends the run
+			previous := time.Duration(0)
+			for k := 1; k <= tt.steps; k++ {
+				due := motion.stepTime(k)
+				if due <= previous {
+					t.Fatalf("step %d due at %s, not after step %d at %s", k, due, k-1, previous)
+				}
+				previous = due
+			}
+			if !near(previous, motion.Duration()) {
+				t.Errorf("last step due at %s, run takes %s", previous, motion.Duration())
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+// valveFlow is the flow of a gravity-fed bottle through a solenoid valve.
+// By Torricelli's law the flow grows with the square root of the liquid
+// head. The head is the filling level plus the head offset, the drop from
+// the bottom of the bottle to the valve expressed in ml of bottle volume,
+// which holds for bottles with straight sides.
+type valveFlow struct {
+	coefficient float64 // ml/s per square root of a ml of head
+	offsetMl    float64
+}
+
+// newValveFlow returns the flow model of a calibrated valve pump
+func newValveFlow(pump *models.Pump) (valveFlow, error) {
+	if pump.ValveFlowMlPerSec == nil || *pump.ValveFlowMlPerSec <= 0 || pump.ValveReferenceLevelInMl == nil {
+		return valveFlow{}, errors.New("pump is not fully configured")
+	}
+
+	var offset float64
+	if pump.ValveHeadOffsetInMl != nil {
+		offset = float64(*pump.ValveHeadOffsetInMl)
+	}
+	head := float64(*pump.ValveReferenceLevelInMl) + offset
+	if head <= 0 {
+		return valveFlow{}, errors.New("pump is not fully configured")
+	}
+	return valveFlow{coefficient: *pump.ValveFlowMlPerSec / math.Sqrt(head), offsetMl: offset}, nil
+}
+
+// rate returns the flow in ml/s with the bottle at levelMl
+func (f valveFlow) rate(levelMl float64) float64 {
+	return f.coefficient * math.Sqrt(max(levelMl, 0)+f.offsetMl)
+}
+
+// pourTime returns how long the valve has to be open to pour amountMl from
+// a bottle at levelMl. The head drops while the valve pours, and solving
+// dV/dt = -k·√(V+h) gives t = 2/k·(√(V₀+h) − √(V₁+h)). A level below the
+// amount is wrong, the bottle is then taken to hold just the amount.
+func (f valveFlow) pourTime(levelMl, amountMl float64) time.Duration {
+	levelMl = max(levelMl, amountMl)
+	seconds := 2 / f.coefficient * (math.Sqrt(levelMl+f.offsetMl) - math.Sqrt(levelMl-amountMl+f.offsetMl))
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// poured returns how much the valve pours in duration from a bottle at
+// levelMl, at most the whole bottle
+func (f valveFlow) poured(levelMl float64, duration time.Duration) float64 {
+	levelMl = max(levelMl, 0)
+	root := math.Sqrt(levelMl+f.offsetMl) - f.coefficient*duration.Seconds()/2
+	if root <= math.Sqrt(f.offsetMl) {
+		return levelMl
+	}
+	return levelMl + f.offsetMl - root*root
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ManfredRichthofen/Bar-Pi/backend-go/internal/models"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestNewValveFlow(t *testing.T) {
+	tests := []struct {
+		name        string
+		pump        models.Pump
+		wantErr     bool
+		coefficient float64
+	}{
+		{
+			name:        "flow at the reference level",
+			pump:        models.Pump{ValveFlowMlPerSec: floatPtr(10), ValveReferenceLevelInMl: intPtr(400)},
+			coefficient: 0.5,
+		},
+		{
+			name:        "head offset below the bottle",
+			pump:        models.Pump{ValveFlowMlPerSec: floatPtr(10), ValveReferenceLevelInMl: intPtr(300), ValveHeadOffsetInMl: intPtr(100)},
+			coefficient: 0.5,
+		},
+		{
+			name:    "flow missing",
+			pump:    models.Pump{ValveReferenceLevelInMl: intPtr(400)},
+			wantErr: true,
+		},
+		{
+			name:    "reference level missing",
+			pump:    models.Pump{ValveFlowMlPerSec: floatPtr(10)},
+			wantErr: true,
+		},
+		{
+			name:    "no head",
+			pump:    models.Pump{ValveFlowMlPerSec: floatPtr(10), ValveReferenceLevelInMl: intPtr(0)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow, err := newValveFlow(&tt.pump)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newValveFlow succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newValveFlow: %v", err)
+			}
+			if math.Abs(flow.coefficient-tt.coefficient) > 1e-9 {
+				t.Errorf("coefficient = %f, want %f", flow.coefficient, tt.coefficient)
+			}
+			// The calibrated flow is reproduced at the reference level
+			if rate := flow.rate(float64(*tt.pump.ValveReferenceLevelInMl)); math.Abs(rate-*tt.pump.ValveFlowMlPerSec) > 1e-9 {
+				t.Errorf("rate at the reference level = %f, want %f", rate, *tt.pump.ValveFlowMlPerSec)
+			}
+		})
+	}
+}
+
+func TestValveFlowPourTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		flow     valveFlow
+		levelMl  float64
+		amountMl float64
+		want     time.Duration
+	}{
+		{name: "nothing", flow: valveFlow{coefficient: 0.5}, levelMl: 400, amountMl: 0, want: 0},
+		{name: "from a full bottle", flow: valveFlow{coefficient: 0.5}, levelMl: 400, amountMl: 175, want: 20 * time.Second},
+		{name: "the rest of the bottle", flow: valveFlow{coefficient: 0.5}, levelMl: 100, amountMl: 100, want: 40 * time.Second},
+		{name: "level below the amount", flow: valveFlow{coefficient: 0.5}, levelMl: 100, amountMl: 400, want: 80 * time.Second},
+		{name: "with a head offset", flow: valveFlow{coefficient: 0.5, offsetMl: 100}, levelMl: 300, amountMl: 204, want: 24 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.flow.pourTime(tt.levelMl, tt.amountMl)
+			if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("pourTime = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValveFlowPoured(t *testing.T) {
+	tests := []struct {
+		name     string
+		flow     valveFlow
+		levelMl  float64
+		duration time.Duration
+		want     float64
+	}{
+		{name: "closed", flow: valveFlow{coefficient: 0.5}, levelMl: 400, duration: 0, want: 0},
+		{name: "from a full bottle", flow: valveFlow{coefficient: 0.5}, levelMl: 400, duration: 20 * time.Second, want: 175},
+		{name: "with a head offset", flow: valveFlow{coefficient: 0.5, offsetMl: 100}, levelMl: 300, duration: 24 * time.Second, want: 204},
+		{name: "bottle runs empty", flow: valveFlow{coefficient: 0.5}, levelMl: 100, duration: time.Hour, want: 100},
+		{name: "bottle runs empty with a head offset", flow: valveFlow{coefficient: 0.5, offsetMl: 100}, levelMl: 300, duration: time.Hour, want: 300},
+		{name: "empty bottle", flow: valveFlow{coefficient: 0.5}, levelMl: -5, duration: time.Second, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.flow.poured(tt.levelMl, tt.duration)
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("poured = %f, want %f", got, tt.want)
+			}
+
+			// Pouring the amount takes the time it was poured in
+			if got > 0 && got < tt.levelMl {
+				if back := tt.flow.pourTime(tt.levelMl, got); math.Abs((back - tt.duration).Seconds()) > 1e-6 {
+					t.Errorf("pourTime of the poured amount = %s, want %s", back, tt.duration)
+				}
+			}
+		})
+	}
+}
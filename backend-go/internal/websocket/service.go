@@ -3,6 +3,7 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"strconv"
 )
 
 // WebSocket destination constants matching the Spring Boot backend
@@ -13,6 +14,8 @@ const (
 	WS_ACTIONS_LOG_DESTINATION        = "/topic/eventactionlog"
 	WS_DISPENSING_AREA                = "/topic/dispensingarea"
 	WS_PUMP_RUNNING_STATE_DESTINATION = "/topic/pump/runningstate"
+	WS_PUMP_CLEANING_DESTINATION      = "/topic/pump/cleaning"
+	WS_PUMP_LOW_LEVEL_DESTINATION     = "/topic/pump/lowlevel"
 	WS_UI_STATE_INFOS                 = "/topic/uistateinfos"
 )
 
@@ -60,34 +63,44 @@ func (s *Service) SendRunningEventActionsStatusToUser(status any, username strin
 
 // BroadcastEventActionLog broadcasts event action log
 func (s *Service) BroadcastEventActionLog(actionID int64, logEntries any) {
-	destination := WS_ACTIONS_LOG_DESTINATION + "/" + string(rune(actionID))
+	destination := WS_ACTIONS_LOG_DESTINATION + "/" + strconv.FormatInt(actionID, 10)
 	s.broadcastJSON(destination, logEntries)
 }
 
 // SendEventActionLogToUser sends event action log to a specific user
 func (s *Service) SendEventActionLogToUser(actionID int64, logEntries any, username string) {
-	destination := WS_ACTIONS_LOG_DESTINATION + "/" + string(rune(actionID))
+	destination := WS_ACTIONS_LOG_DESTINATION + "/" + strconv.FormatInt(actionID, 10)
 	s.sendJSONToUser(username, destination, logEntries)
 }
 
 // BroadcastClearEventActionLog broadcasts a clear signal for event action log
 func (s *Service) BroadcastClearEventActionLog(actionID int64) {
-	destination := WS_ACTIONS_LOG_DESTINATION + "/" + string(rune(actionID))
-	s.stompServer.Broadcast(destination, "DELETE")
+	destination := WS_ACTIONS_LOG_DESTINATION + "/" + strconv.FormatInt(actionID, 10)
+	s.broadcast(destination, "DELETE")
 }
 
 // BroadcastPumpRunningState broadcasts pump running state
 func (s *Service) BroadcastPumpRunningState(pumpID int64, state any) {
-	destination := WS_PUMP_RUNNING_STATE_DESTINATION + "/" + string(rune(pumpID))
+	destination := WS_PUMP_RUNNING_STATE_DESTINATION + "/" + strconv.FormatInt(pumpID, 10)
 	s.broadcastJSON(destination, state)
 }
 
 // SendPumpRunningStateToUser sends pump running state to a specific user
 func (s *Service) SendPumpRunningStateToUser(pumpID int64, state any, username string) {
-	destination := WS_PUMP_RUNNING_STATE_DESTINATION + "/" + string(rune(pumpID))
+	destination := WS_PUMP_RUNNING_STATE_DESTINATION + "/" + strconv.FormatInt(pumpID, 10)
 	s.sendJSONToUser(username, destination, state)
 }
 
+// BroadcastPumpCleaning broadcasts the state of the cleaning program
+func (s *Service) BroadcastPumpCleaning(state any) {
+	s.broadcastJSON(WS_PUMP_CLEANING_DESTINATION, state)
+}
+
+// BroadcastPumpLowLevel broadcasts a warning for a pump that ran low
+func (s *Service) BroadcastPumpLowLevel(warning any) {
+	s.broadcastJSON(WS_PUMP_LOW_LEVEL_DESTINATION, warning)
+}
+
 // BroadcastDetectedGlass broadcasts detected glass state
 func (s *Service) BroadcastDetectedGlass(state any) {
 	s.broadcastJSON(WS_DISPENSING_AREA, state)
@@ -100,7 +113,7 @@ func (s *Service) SendDetectedGlassToUser(state any, username string) {
 
 // InvalidateRecipeScrollCaches broadcasts a cache invalidation message
 func (s *Service) InvalidateRecipeScrollCaches() {
-	s.stompServer.Broadcast(WS_UI_STATE_INFOS, "INVALIDATE_CACHED_RECIPES")
+	s.broadcast(WS_UI_STATE_INFOS, "INVALIDATE_CACHED_RECIPES")
 }
 
 // Helper methods
@@ -111,7 +124,15 @@ func (s *Service) broadcastJSON(destination string, data any) {
 		log.Printf("Error marshaling data for broadcast: %v", err)
 		return
 	}
-	s.stompServer.Broadcast(destination, string(jsonData))
+	s.broadcast(destination, string(jsonData))
+}
+
+// broadcast sends a message to the subscribers of a destination. The
+// frontend subscribes to user prefixed destinations, so those receive the
+// message as well.
+func (s *Service) broadcast(destination string, message string) {
+	s.stompServer.Broadcast(destination, message)
+	s.stompServer.Broadcast("/user"+destination, message)
 }
 
 func (s *Service) sendJSONToUser(username string, destination string, data any) {